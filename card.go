@@ -0,0 +1,130 @@
+package marianne
+
+import (
+	"io"
+	"math"
+	"strings"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// les dimensions de la carte de visite (en centièmes de mm, comme le logo)
+const (
+	// le format fini de la carte (85 x 55 mm)
+	cardWidth, cardHeight = 8500.0, 5500.0
+	// le fond perdu (3 mm) et la marge autour de la carte où sont les traits de coupe (10 mm)
+	cardBleed, cardSlug = 300.0, 1000.0
+	// la zone de sécurité à l'intérieur du format fini (5 mm)
+	cardSafe = 500.0
+	// les traits de coupe : leur épaisseur (0,1 mm) et leur distance au format fini (4 mm)
+	cardMarkWidth, cardMarkGap = 10.0, 400.0
+	// l'échelle maximale du bloc-marque : la Marianne fait alors 4 mm de haut
+	cardLogoScale = 0.4
+	// le nom (hauteur du "A" de 10 pt), la fonction et les coordonnées (7 pt) et leurs interlignes
+	cardNameSize, cardTextSize, cardTextStep = 250.0, 173.0, 120.0
+)
+
+// RenderBusinessCard dessine la carte de visite (85 x 55 mm) de l'agent a sur deux pages :
+// au recto le bloc-marque en haut à gauche et le nom avec la fonction en bas à gauche, au
+// verso l'adresse, le téléphone et l'adresse électronique. Chaque page a un fond perdu de
+// 3 mm et des traits de coupe dans une marge de 10 mm autour de la carte.
+func RenderBusinessCard(opts Options, a Agent) ([]Page, error) {
+	opts = opts.normalize()
+	// le bloc-marque seul (sans marges ni fond)
+	lo := opts
+	lo.NoMargins, lo.Transparent = true, true
+	logo, err := Render(lo)
+	if err != nil {
+		return nil, err
+	}
+	fontFamily, err := loadFont()
+	if err != nil {
+		return nil, &Error{Kind: FontError, Op: "chargement de la police Marianne", Err: err}
+	}
+	col := opts.colors().text
+
+	// le recto
+	front, ctx := newCardPage(opts)
+	// le nom et la fonction, alignés en bas (en remontant depuis la zone de sécurité)
+	y := cardHeight - cardSafe
+	if title := oneLine(a.Title, opts.EOL); title != "" {
+		y -= cardTextSize
+		drawText(ctx, fontFamily, col, title, "", cardSafe, y, cardTextSize, cardTextStep, Wrap{})
+		y -= cardTextStep
+	}
+	if name := oneLine(a.Name, opts.EOL); name != "" {
+		y -= cardNameSize
+		drawText(ctx, fontFamily, col, name, "", cardSafe, y, cardNameSize, cardTextStep, Wrap{})
+		y -= cardTextStep
+	}
+	// la hauteur occupée par le texte et l'espace qui le sépare du bloc-marque
+	top := cardHeight - cardSafe - y
+	if top > 0 {
+		top += x * cardLogoScale
+	}
+	scale := math.Min(cardLogoScale, math.Min((cardWidth-2*cardSafe)/logo.W, (cardHeight-2*cardSafe-top)/logo.H))
+	ctx.SetView(canvas.Identity.Translate(cardSlug+cardSafe, cardSlug+cardHeight-cardSafe-logo.H*scale).Scale(scale, scale))
+	logo.Render(ctx)
+
+	// le verso : les coordonnées alignées en bas à gauche
+	back, ctx := newCardPage(opts)
+	lines := textLines(a.Address, opts.EOL)
+	if phone := strings.TrimSpace(a.Phone); phone != "" {
+		lines = append(lines, "Tél. : "+phone)
+	}
+	if email := strings.TrimSpace(a.Email); email != "" {
+		lines = append(lines, email)
+	}
+	if n := float64(len(lines)); n > 0 {
+		drawText(ctx, fontFamily, col, strings.Join(lines, "\n"), "", cardSafe, cardHeight-cardSafe-n*cardTextSize-(n-1)*cardTextStep, cardTextSize, cardTextStep, Wrap{})
+	}
+	return []Page{front, back}, nil
+}
+
+// newCardPage prépare une page de la carte de visite avec son fond perdu et ses traits de
+// coupe, et retourne un contexte où drawText compte les ordonnées vers le bas depuis le
+// haut de la carte
+func newCardPage(opts Options) (Page, *canvas.Context) {
+	w, h := cardWidth+2*cardSlug, cardHeight+2*cardSlug
+	p := Page{
+		Canvas: canvas.New(w, h),
+		Trim:   canvas.Rect{X: cardSlug, Y: cardSlug, W: cardWidth, H: cardHeight},
+		Bleed:  canvas.Rect{X: cardSlug - cardBleed, Y: cardSlug - cardBleed, W: cardWidth + 2*cardBleed, H: cardHeight + 2*cardBleed},
+	}
+	ctx := canvas.NewContext(p.Canvas)
+	if !opts.Transparent {
+		ctx.SetFillColor(opts.BackgroundColor())
+		ctx.DrawPath(p.Bleed.X, p.Bleed.Y, canvas.Rectangle(p.Bleed.W, p.Bleed.H))
+	}
+	// les traits de coupe (des rectangles pleins) dans le prolongement du format fini,
+	// de la distance cardMarkGap jusqu'au bord de la page
+	ctx.SetFillColor(canvas.Black)
+	length := cardSlug - cardMarkGap
+	for _, xm := range []float64{cardSlug, cardSlug + cardWidth} {
+		for _, ym := range []float64{0, h - length} {
+			ctx.DrawPath(xm-cardMarkWidth/2, ym, canvas.Rectangle(cardMarkWidth, length))
+		}
+	}
+	for _, ym := range []float64{cardSlug, cardSlug + cardHeight} {
+		for _, xm := range []float64{0, w - length} {
+			ctx.DrawPath(xm, ym-cardMarkWidth/2, canvas.Rectangle(length, cardMarkWidth))
+		}
+	}
+	ctx.SetView(canvas.Identity.Translate(cardSlug, cardSlug+cardHeight))
+	return p, ctx
+}
+
+// EncodePages écrit en PDF les pages (par exemple la carte de visite de RenderBusinessCard)
+// à leur taille réelle, une unité valant 1/100 mm, avec les couleurs d'impression et la
+// norme des options
+func EncodePages(w io.Writer, pages []Page, opts Options) error {
+	opts = opts.normalize()
+	if len(pages) == 0 {
+		return parameterError("aucune page à écrire")
+	}
+	mm := make([]Page, len(pages))
+	for i, p := range pages {
+		mm[i] = p.inMillimeters()
+	}
+	return encode(w, "PDF", func(w io.Writer) error { return writePDF(w, mm, opts) })
+}
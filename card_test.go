@@ -0,0 +1,51 @@
+package marianne
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+// boxes retourne les valeurs des boîtes name (TrimBox, BleedBox...) des pages du PDF
+func boxes(data []byte, name string) []string {
+	var b []string
+	for _, m := range regexp.MustCompile(`/`+name+` \[([^\]]*)\]`).FindAllSubmatch(data, -1) {
+		b = append(b, string(m[1]))
+	}
+	return b
+}
+
+func TestBusinessCard(t *testing.T) {
+	a := Agent{Name: "Camille Durand", Title: "Cheffe de projet", Phone: "01 23 45 67 89",
+		Address: "20 avenue de Ségur\\75007 Paris", Email: "camille.durand@exemple.gouv.fr"}
+	pages, err := RenderBusinessCard(DefaultOptions(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("%d pages au lieu du recto et du verso", len(pages))
+	}
+	var buf bytes.Buffer
+	if err := EncodePages(&buf, pages, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	checkXref(t, data)
+	// la page : la carte (85 x 55 mm) dans une marge de 10 mm, avec un fond perdu de 3 mm
+	for name, want := range map[string]string{
+		"MediaBox": "0 0 297.6378 212.5984",
+		"TrimBox":  "28.3465 28.3465 269.2913 184.252",
+		"BleedBox": "19.8425 19.8425 277.7953 192.7559",
+	} {
+		b := boxes(data, name)
+		if len(b) != 2 || b[0] != want || b[1] != want {
+			t.Errorf("%s %q au lieu de [%s] sur les deux pages", name, b, want)
+		}
+	}
+	// la carte est à la même taille sur les pages (en 1/100 mm)
+	for i, p := range pages {
+		if p.Trim.W != cardWidth || p.Trim.H != cardHeight || p.Bleed.W != cardWidth+2*cardBleed {
+			t.Errorf("page %d : format fini %v et fond perdu %v", i+1, p.Trim, p.Bleed)
+		}
+	}
+}
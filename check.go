@@ -0,0 +1,191 @@
+package marianne
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Severity est la gravité d'un écart à la charte signalé par Check
+type Severity int
+
+// les gravités
+const (
+	// l'écart est déconseillé par la charte
+	Warning Severity = iota + 1
+	// l'écart est contraire à la charte
+	Violation
+)
+
+// String retourne la gravité en français
+func (s Severity) String() string {
+	if s == Violation {
+		return "erreur"
+	}
+	return "avertissement"
+}
+
+// Issue est un écart à la charte signalé par Check
+type Issue struct {
+	// La gravité de l'écart.
+	Severity Severity
+	// La description de l'écart en français.
+	Message string
+}
+
+// String retourne la gravité et la description de l'écart
+func (i Issue) String() string {
+	return i.Severity.String() + " : " + i.Message
+}
+
+// les limites vérifiées par Check
+const (
+	// le nombre maximal de lignes de l'institution et de la direction
+	maxInstitutionLines, maxDirectionLines = 5, 4
+	// la longueur conseillée de l'intitulé de la direction (en caractères)
+	maxDirectionLength = 80
+	// la largeur maximale d'une ligne, en largeurs de Marianne : dans le bloc-marque la
+	// Marianne est bien plus étroite que les textes (la ligne « RÉPUBLIQUE » imposée par la
+	// charte en mesure déjà 2,4), une ligne plus large que la Marianne est donc la règle ;
+	// au-delà de 4 (« DE L'INTÉRIEUR » en mesure 3) le texte déséquilibre le bloc-marque
+	maxLineWidth = 4
+	// la hauteur minimale (en pixels) du plus petit texte, lisible puis confortable
+	minTextPixels, goodTextPixels = 5, 7
+	// le contraste minimal entre le texte et le fond (comme le RGAA pour les textes)
+	minContrast = 4.5
+)
+
+// les mots fréquents des intitulés qui prennent un accent, même en capitales
+var accentedWords = map[string]string{
+	"REPUBLIQUE": "RÉPUBLIQUE", "FRANCAISE": "FRANÇAISE", "MINISTERE": "MINISTÈRE", "ETAT": "ÉTAT",
+	"ECONOMIE": "ÉCONOMIE", "EDUCATION": "ÉDUCATION", "SECURITE": "SÉCURITÉ", "SANTE": "SANTÉ",
+	"PREFET": "PRÉFET", "PREFECTURE": "PRÉFECTURE", "PREFETE": "PRÉFÈTE", "ECOLOGIQUE": "ÉCOLOGIQUE",
+	"SOLIDARITES": "SOLIDARITÉS", "EGALITE": "ÉGALITÉ", "SUPERIEUR": "SUPÉRIEUR", "ETRANGERES": "ÉTRANGÈRES",
+	"DEFENSE": "DÉFENSE", "ARMEES": "ARMÉES", "INTERIEUR": "INTÉRIEUR", "REGION": "RÉGION",
+	"REGIONALE": "RÉGIONALE", "DEPARTEMENT": "DÉPARTEMENT", "GENERALE": "GÉNÉRALE", "DELEGATION": "DÉLÉGATION",
+	"INTERMINISTERIELLE": "INTERMINISTÉRIELLE", "COHESION": "COHÉSION", "ENERGETIQUE": "ÉNERGÉTIQUE",
+	"EUROPEENNES": "EUROPÉENNES", "PRESIDENCE": "PRÉSIDENCE", "SECRETARIAT": "SECRÉTARIAT",
+}
+
+// Check signale les écarts à la charte du logo décrit par opts, enregistré dans les formats
+// et (pour les images) aux hauteurs en pixels données : l'institution qui ne peut pas être
+// écrite en capitales (ou sans ses accents), trop de lignes, une direction trop longue, des
+// lignes trop larges par rapport à la Marianne, un texte illisible aux petites hauteurs, un
+// JPEG pour l'impression ou un contraste insuffisant. Les mesures sont celles de drawText.
+func Check(opts Options, formats []string, heights []uint) ([]Issue, error) {
+	opts = opts.normalize()
+	fontFamily, err := loadFont()
+	if err != nil {
+		return nil, &Error{Kind: FontError, Op: "chargement de la police Marianne", Err: err}
+	}
+	var issues []Issue
+	add := func(s Severity, format string, a ...interface{}) {
+		issues = append(issues, Issue{s, fmt.Sprintf(format, a...)})
+	}
+
+	// l'institution, écrite en capitales (avec la taille de drawLogo)
+	if strings.IndexFunc(opts.Institution, unicode.IsLower) >= 0 {
+		add(Warning, "l'institution n'est pas en capitales (%q) : elle est mise en capitales dans le logo", opts.Institution)
+	}
+	institution := strings.ToUpper(opts.Institution)
+	lines, widths := measureText(fontFamily, institution, opts.EOL, 3*x/4, opts.Wrap)
+	if strings.IndexFunc(institution, unicode.IsLower) >= 0 {
+		add(Violation, "l'institution contient des lettres sans capitale (%q)", institution)
+	}
+	for _, w := range strings.FieldsFunc(institution, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if a, ok := accentedWords[w]; ok {
+			add(Warning, "les capitales gardent leurs accents : %q s'écrit %q", w, a)
+		}
+	}
+	if len(lines) > maxInstitutionLines {
+		add(Violation, "l'institution a %d lignes (au plus %d)", len(lines), maxInstitutionLines)
+	}
+	checkWidths := func(name string, lines []string, widths []float64) {
+		for i, w := range widths {
+			if r := w / symbolWidth; r > maxLineWidth {
+				add(Warning, "la ligne %q de %s est %.1f fois plus large que la Marianne (au plus %d) : coupez-la en plusieurs lignes", lines[i], name, r, maxLineWidth)
+			}
+		}
+	}
+	checkWidths("l'institution", lines, widths)
+
+	// la direction
+	if strings.TrimSpace(opts.Direction) != "" {
+		lines, widths := measureText(fontFamily, opts.Direction, opts.EOL, 11*x/20, opts.Wrap)
+		if len(lines) > maxDirectionLines {
+			add(Violation, "la direction a %d lignes (au plus %d)", len(lines), maxDirectionLines)
+		}
+		if n := len([]rune(strings.Join(lines, " "))); n > maxDirectionLength {
+			add(Warning, "l'intitulé de la direction est long (%d caractères, au plus %d conseillés) : préférez une forme courte", n, maxDirectionLength)
+		}
+		if d := strings.Join(lines, ""); strings.IndexFunc(d, unicode.IsLower) < 0 && strings.IndexFunc(d, unicode.IsUpper) >= 0 {
+			add(Warning, "la direction est en capitales : elle s'écrit en minuscules avec une capitale initiale")
+		}
+		checkWidths("la direction", lines, widths)
+	}
+
+	// la lisibilité du plus petit texte (la direction, sinon l'institution) dans les images
+	var raster, jpg bool
+	for _, f := range formats {
+		f = NormalizeFormat(f)
+		raster = raster || IsRaster(f)
+		jpg = jpg || f == "jpg"
+	}
+	if raster {
+		c, err := Render(opts)
+		if err != nil {
+			return nil, err
+		}
+		size := 3 * x / 4
+		if strings.TrimSpace(opts.Direction) != "" {
+			size = 11 * x / 20
+		}
+		if len(heights) == 0 {
+			heights = []uint{opts.Height}
+		}
+		for _, h := range heights {
+			px := size * float64(h) / c.H
+			if px < minTextPixels {
+				add(Violation, "à %d pixels de haut les capitales du plus petit texte font %.1f pixels : le texte est illisible (au moins %d pixels, soit une hauteur de %d)", h, px, minTextPixels, int(math.Ceil(minTextPixels*c.H/size)))
+			} else if px < goodTextPixels {
+				add(Warning, "à %d pixels de haut les capitales du plus petit texte font %.1f pixels : le texte est difficile à lire (%d pixels conseillés, soit une hauteur de %d)", h, px, goodTextPixels, int(math.Ceil(goodTextPixels*c.H/size)))
+			}
+		}
+	}
+
+	// les formats et les couleurs
+	if jpg && (opts.CMYK || opts.PDFStandard == PDFX4) {
+		add(Violation, "le JPEG n'est pas adapté à l'impression (compression avec perte, en RVB) : utilisez le PDF ou l'EPS")
+	}
+	if jpg && opts.Transparent {
+		add(Warning, "le JPEG n'a pas de transparence : le logo y est sur son fond")
+	}
+	colors := opts.colors()
+	if r := contrast(colors.text, colors.background); r < minContrast {
+		add(Warning, "le contraste entre le texte (%s) et le fond (%s) est de %.1f (au moins %.1f)", FormatColor(colors.text), FormatColor(colors.background), r, minContrast)
+	}
+	return issues, nil
+}
+
+// contrast retourne le rapport de contraste (de 1 à 21) entre les couleurs a et b
+func contrast(a, b color.RGBA) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// luminance retourne la luminance relative de la couleur c
+func luminance(c color.RGBA) float64 {
+	lin := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.03928 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	return 0.2126*lin(c.R) + 0.7152*lin(c.G) + 0.0722*lin(c.B)
+}
@@ -0,0 +1,50 @@
+package marianne
+
+import (
+	"strings"
+	"testing"
+)
+
+// hasIssue indique si un des écarts a la gravité s et contient le texte msg
+func hasIssue(issues []Issue, s Severity, msg string) bool {
+	for _, i := range issues {
+		if i.Severity == s && strings.Contains(i.Message, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		formats  []string
+		heights  []uint
+		severity Severity
+		msg      string // vide si aucun écart n'est attendu
+	}{
+		{"défaut", DefaultOptions(), []string{"svg"}, nil, 0, ""},
+		{"minuscules", Options{Institution: "Ministère\\de l'Intérieur", EOL: "\\"}, []string{"svg"}, nil, Warning, "n'est pas en capitales"},
+		{"sans majuscule", Options{Institution: "STRAßE", EOL: "\\"}, []string{"svg"}, nil, Violation, "sans capitale"},
+		{"accents", Options{Institution: "REPUBLIQUE\\FRANÇAISE", EOL: "\\"}, []string{"svg"}, nil, Warning, `"RÉPUBLIQUE"`},
+		{"lignes", Options{Institution: "A\\B\\C\\D\\E\\F", EOL: "\\"}, []string{"svg"}, nil, Violation, "6 lignes"},
+		{"ligne large", Options{Institution: "MINISTÈRE DE L'INTÉRIEUR", EOL: "\\"}, []string{"svg"}, nil, Warning, "plus large que la Marianne"},
+		{"direction en capitales", Options{Institution: "RÉPUBLIQUE", Direction: "DIRECTION", EOL: "\\"}, []string{"svg"}, nil, Warning, "en capitales"},
+		{"illisible", DefaultOptions(), []string{"png"}, []uint{30}, Violation, "illisible"},
+		{"jpeg imprimé", Options{Institution: "RÉPUBLIQUE", CMYK: true}, []string{"jpg"}, nil, Violation, "JPEG"},
+	}
+	for _, tt := range tests {
+		issues, err := Check(tt.opts, tt.formats, tt.heights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.msg == "" {
+			if len(issues) > 0 {
+				t.Errorf("%s : écarts inattendus %v", tt.name, issues)
+			}
+		} else if !hasIssue(issues, tt.severity, tt.msg) {
+			t.Errorf("%s : %s %q attendu, obtenu %v", tt.name, tt.severity, tt.msg, issues)
+		}
+	}
+}
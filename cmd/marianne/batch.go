@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kpym/marianne"    // la génération du logo
+	flag "github.com/spf13/pflag" // pour les paramètres en ligne de commande
+	"gopkg.in/yaml.v2"            // pour les fichiers de lot en YAML
+)
+
+// une entrée du fichier de lot : les valeurs des paramètres (avec les mêmes noms
+// que ceux de la ligne de commande, par exemple "nom-du-logo" ou "hauteur")
+type entree map[string]string
+
+// la valeur sauvée d'un paramètre de la ligne de commande
+type parametre struct {
+	valeur  string   // la valeur (paramètres simples)
+	liste   []string // les valeurs (paramètres à plusieurs valeurs)
+	modifie bool     // le paramètre a été donné en ligne de commande
+}
+
+// parametres garde les valeurs de tous les paramètres de la ligne de commande
+// (par leur nom), pour les remettre avant chaque entrée du lot
+type parametres map[string]parametre
+
+// sauve les valeurs actuelles des paramètres
+func sauverParametres() parametres {
+	p := parametres{}
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		s := parametre{valeur: f.Value.String(), modifie: f.Changed}
+		if l, ok := f.Value.(flag.SliceValue); ok {
+			s.liste = l.GetSlice()
+		}
+		p[f.Name] = s
+	})
+	return p
+}
+
+// restaure les valeurs des paramètres sauvées avec sauverParametres (les valeurs
+// sauvées sont toujours valides, les erreurs sont donc ignorées)
+func (p parametres) restaurer() {
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		s, ok := p[f.Name]
+		if !ok {
+			return
+		}
+		if l, ok := f.Value.(flag.SliceValue); ok {
+			l.Replace(s.liste)
+		} else {
+			f.Value.Set(s.valeur)
+		}
+		f.Changed = s.modifie
+	})
+}
+
+// découpe une liste de valeurs séparées par des virgules, des points-virgules ou des espaces
+func splitList(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	})
+}
+
+// appliquer modifie les paramètres en fonction des valeurs de l'entrée (dans l'ordre
+// alphabétique des noms, pour signaler toujours la même erreur)
+func (e entree) appliquer() error {
+	cles := make([]string, 0, len(e))
+	for k := range e {
+		cles = append(cles, k)
+	}
+	sort.Strings(cles)
+	for _, k := range cles {
+		v := e[k]
+		var err error
+		switch k {
+		case "nom-du-logo":
+			nom = v
+		case "dossier":
+			dossier = v
+		case "modele":
+			modele = v
+		case "modele-vectoriel":
+			modeleVect = v
+		case "institution":
+			institution = v
+		case "direction":
+			direction = v
+		case "eol":
+			eol = v
+		case "lignes":
+			err = lireInt(&lignes, k, v)
+		case "largeur-texte":
+			err = lireFloat(&largeurTexte, k, v)
+		case "format":
+			formats = splitList(v)
+		case "hauteur":
+			hauteurs = nil
+			for _, h := range splitList(v) {
+				n, e := strconv.ParseUint(h, 10, 32)
+				if e != nil {
+					return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("hauteur invalide : %q", h)}
+				}
+				hauteurs = append(hauteurs, uint(n))
+			}
+		case "largeur":
+			largeurs = nil
+			for _, l := range splitList(v) {
+				n, e := strconv.ParseUint(l, 10, 32)
+				if e != nil {
+					return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("largeur invalide : %q", l)}
+				}
+				largeurs = append(largeurs, uint(n))
+			}
+		case "densites":
+			densites = nil
+			for _, d := range splitList(v) {
+				n, e := strconv.ParseUint(d, 10, 32)
+				if e != nil {
+					return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("densité invalide : %q", d)}
+				}
+				densites = append(densites, uint(n))
+			}
+		case "surechantillonnage":
+			n, e := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+			if e != nil {
+				return valeurInvalide(k, v)
+			}
+			surech = uint(n)
+		case "filtre":
+			filtre = v
+		case "alignement-pixels":
+			err = lireBool(&alignement, k, v)
+		case "taille":
+			tailles = splitList(v)
+		case "dpi":
+			err = lireFloat(&dpi, k, v)
+		case "avec-marges":
+			err = lireBool(&avecMarges, k, v)
+		case "sans-marges":
+			err = lireBool(&sansMarges, k, v)
+		case "pour-signature":
+			err = lireBool(&pourSignature, k, v)
+		case "agent":
+			agent = v
+		case "fonction":
+			fonction = v
+		case "telephone":
+			telephone = v
+		case "adresse":
+			adresse = v
+		case "courriel":
+			courriel = v
+		case "url-image":
+			urlImage = v
+		case "papier-en-tete":
+			err = lireBool(&papierEnTete, k, v)
+		case "pied-de-page":
+			piedDePage = v
+		case "carte-de-visite":
+			err = lireBool(&carteDeVisite, k, v)
+		case "co-marque":
+			// les blocs-marques sont séparés par des points-virgules (voir lireCoMarquage)
+			coMarques = []string{v}
+		case "partenaire":
+			partenaires = splitList(v)
+		case "disposition":
+			disposition = v
+		case "seize-couleurs":
+			err = lireBool(&col16, k, v)
+		case "couleurs":
+			err = lireInt(&couleurs, k, v)
+		case "tramage":
+			err = lireBool(&tramage, k, v)
+		case "optimisation-png":
+			err = lireInt(&optimPNG, k, v)
+		case "transparent":
+			err = lireBool(&transparent, k, v)
+		case "variante":
+			variante = v
+		case "fond":
+			fond = v
+		case "mode":
+			mode = v
+		case "encre":
+			encre = v
+		case "impression":
+			err = lireBool(&impression, k, v)
+		case "tons-directs":
+			err = lireBool(&tonsDirects, k, v)
+		case "table-couleurs":
+			tableCouleurs = v
+		case "norme-pdf":
+			normePDF = v
+		case "icc":
+			icc = v
+		case "qualite-jpg":
+			err = lireInt(&jpgq, k, v)
+		case "qualite-webp":
+			err = lireInt(&webpq, k, v)
+		default:
+			return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("paramètre inconnu : %q", k)}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// chaque entrée doit avoir son propre nom (sinon les fichiers seraient écrasés)
+	if strings.TrimSpace(e["nom-du-logo"]) == "" {
+		return &marianne.Error{Kind: marianne.ParameterError, Op: "le nom du logo (nom-du-logo) est manquant"}
+	}
+	return nil
+}
+
+// valeurInvalide retourne l'erreur de la valeur v invalide pour le paramètre k
+func valeurInvalide(k, v string) error {
+	return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("valeur invalide pour %s : %q", k, v)}
+}
+
+// lireBool lit dans b le booléen v du paramètre k (b n'est pas modifié si v est invalide)
+func lireBool(b *bool, k, v string) error {
+	x, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return valeurInvalide(k, v)
+	}
+	*b = x
+	return nil
+}
+
+// lireInt lit dans n l'entier v du paramètre k (n n'est pas modifié si v est invalide)
+func lireInt(n *int, k, v string) error {
+	x, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return valeurInvalide(k, v)
+	}
+	*n = x
+	return nil
+}
+
+// lireFloat lit dans f le nombre v du paramètre k (f n'est pas modifié si v est invalide)
+func lireFloat(f *float64, k, v string) error {
+	x, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return valeurInvalide(k, v)
+	}
+	*f = x
+	return nil
+}
+
+// transforme une valeur JSON ou YAML en chaîne (les listes sont séparées par des virgules)
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		var l []string
+		for _, x := range v {
+			l = append(l, toString(x))
+		}
+		return strings.Join(l, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// lit les entrées d'un fichier CSV (la première ligne contient les noms des paramètres)
+func readCSV(data []byte) ([]entree, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	// les tableurs en français utilisent souvent le point-virgule
+	if first := bytes.SplitN(data, []byte("\n"), 2)[0]; bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.TrimLeadingSpace = true
+	lignes, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(lignes) == 0 {
+		return nil, nil
+	}
+	var entrees []entree
+	entete := lignes[0]
+	for i := range entete {
+		entete[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(entete[i], "\ufeff")))
+	}
+	for _, ligne := range lignes[1:] {
+		e := entree{}
+		for i, v := range ligne {
+			if v != "" {
+				e[entete[i]] = v
+			}
+		}
+		entrees = append(entrees, e)
+	}
+	return entrees, nil
+}
+
+// lit les entrées d'un fichier JSON ou YAML (une liste d'objets)
+func readList(data []byte, unmarshal func([]byte, interface{}) error) ([]entree, error) {
+	var liste []map[string]interface{}
+	if err := unmarshal(data, &liste); err != nil {
+		return nil, err
+	}
+	var entrees []entree
+	for _, m := range liste {
+		e := entree{}
+		for k, v := range m {
+			e[strings.ToLower(k)] = toString(v)
+		}
+		entrees = append(entrees, e)
+	}
+	return entrees, nil
+}
+
+// readManifest lit le fichier de lot (CSV, JSON ou YAML en fonction de l'extension)
+func readManifest(name string) ([]entree, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, ioError("lecture du fichier de lot", err)
+	}
+	var entrees []entree
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".csv":
+		entrees, err = readCSV(data)
+	case ".json":
+		entrees, err = readList(data, json.Unmarshal)
+	case ".yaml", ".yml":
+		entrees, err = readList(data, yaml.Unmarshal)
+	default:
+		return nil, &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("type de fichier de lot inconnu %q (CSV, JSON ou YAML)", ext)}
+	}
+	if err != nil {
+		return nil, &marianne.Error{Kind: marianne.ParameterError, Op: "fichier de lot " + name, Err: err}
+	}
+	return entrees, nil
+}
+
+// le résultat d'une entrée du lot
+type resultat struct {
+	num int
+	nom string
+	err error
+}
+
+// runBatch génère tous les logos décrits dans le fichier de lot name puis
+// affiche le bilan (les paramètres de la ligne de commande servent de valeurs par défaut)
+func runBatch(name string) error {
+	entrees, err := readManifest(name)
+	if err != nil {
+		return err
+	}
+	if len(entrees) == 0 {
+		return &marianne.Error{Kind: marianne.ParameterError, Op: "le fichier de lot " + name + " est vide"}
+	}
+
+	defauts := sauverParametres()
+	var resultats []resultat
+	for i, e := range entrees {
+		defauts.restaurer()
+		r := resultat{num: i + 1}
+		if r.err = e.appliquer(); r.err == nil {
+			var formatstr string
+			if formatstr, r.err = completeParameters(); r.err == nil {
+				log(fmt.Sprintf("\n=== Logo %d/%d : %s ===\n", i+1, len(entrees), nom))
+				if r.err = run(formatstr); r.err != nil {
+					log("\n")
+				}
+			}
+		}
+		r.nom = nom
+		resultats = append(resultats, r)
+	}
+
+	// le bilan
+	var echecs []resultat
+	log("\nBilan :\n")
+	for _, r := range resultats {
+		if r.err != nil {
+			echecs = append(echecs, r)
+			log(fmt.Sprintf("  entrée %d (%s) : ERREUR (%s) : %v\n", r.num, r.nom, marianne.KindOf(r.err), r.err))
+		} else {
+			log(fmt.Sprintf("  entrée %d (%s) : fait.\n", r.num, r.nom))
+		}
+	}
+	log(fmt.Sprintf("%d logo(s) généré(s), %d échec(s).\n", len(resultats)-len(echecs), len(echecs)))
+	if len(echecs) > 0 {
+		// le code de sortie est celui du premier échec
+		return &marianne.Error{Kind: marianne.KindOf(echecs[0].err), Op: fmt.Sprintf("%d entrée(s) du lot en échec", len(echecs))}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+func TestMain(m *testing.M) {
+	declareFlags()
+	os.Exit(m.Run())
+}
+
+func TestAppliquerValeurInvalide(t *testing.T) {
+	defauts := sauverParametres()
+	defer defauts.restaurer()
+
+	// l'ordre de parcours des entrées est aléatoire : l'erreur doit être détectée à chaque fois
+	manifeste := []byte("nom-du-logo,transparent,qualite-jpg,lignes,dpi,format\nx1,oui,90,2,300,svg\n")
+	for i := 0; i < 50; i++ {
+		entrees, err := readCSV(manifeste)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defauts.restaurer()
+		err = entrees[0].appliquer()
+		if marianne.KindOf(err) != marianne.ParameterError {
+			t.Fatalf("essai %d : le booléen invalide \"oui\" est accepté (erreur %v)", i, err)
+		}
+	}
+}
+
+func TestAppliquerNombresInvalides(t *testing.T) {
+	defauts := sauverParametres()
+	defer defauts.restaurer()
+
+	for _, k := range []string{"lignes", "largeur-texte", "dpi", "couleurs", "qualite-jpg", "qualite-webp", "optimisation-png", "surechantillonnage"} {
+		defauts.restaurer()
+		e := entree{"nom-du-logo": "x", "transparent": "true", k: "beaucoup"}
+		if err := e.appliquer(); marianne.KindOf(err) != marianne.ParameterError {
+			t.Errorf("%s : la valeur invalide est acceptée (erreur %v)", k, err)
+		}
+	}
+}
+
+func TestAppliquerValeurs(t *testing.T) {
+	defauts := sauverParametres()
+	defer defauts.restaurer()
+
+	e := entree{"nom-du-logo": "x", "transparent": "true", "qualite-jpg": "90", "dpi": "150", "hauteur": "100,200",
+		"format": "png", "co-marque": "A|B", "courriel": "a@b.fr"}
+	if err := e.appliquer(); err != nil {
+		t.Fatal(err)
+	}
+	if nom != "x" || !transparent || jpgq != 90 || dpi != 150 || len(hauteurs) != 2 || hauteurs[1] != 200 {
+		t.Errorf("paramètres mal lus : nom=%q transparent=%v jpgq=%d dpi=%g hauteurs=%v", nom, transparent, jpgq, dpi, hauteurs)
+	}
+
+	// restaurer remet toutes les valeurs sauvées
+	defauts.restaurer()
+	if p := sauverParametres(); !reflect.DeepEqual(p, defauts) {
+		for k, v := range p {
+			if !reflect.DeepEqual(v, defauts[k]) {
+				t.Errorf("%s mal restauré : %+v au lieu de %+v", k, v, defauts[k])
+			}
+		}
+	}
+	if len(hauteurs) != 0 || len(formats) != 0 || len(coMarques) != 0 {
+		t.Errorf("listes mal restaurées : hauteurs=%v formats=%v co-marques=%v", hauteurs, formats, coMarques)
+	}
+}
+
+func TestAppliquerPremiereErreur(t *testing.T) {
+	defauts := sauverParametres()
+	defer defauts.restaurer()
+
+	// avec plusieurs valeurs invalides, l'erreur signalée est toujours la même
+	e := entree{"nom-du-logo": "x", "transparent": "oui", "lignes": "deux", "dpi": "beaucoup"}
+	for i := 0; i < 50; i++ {
+		defauts.restaurer()
+		if err := e.appliquer(); err == nil || !strings.Contains(err.Error(), "dpi") {
+			t.Fatalf("essai %d : erreur %v au lieu de celle de dpi", i, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/kpym/marianne"   // la génération du logo
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// le co-marquage (construit à partir des flags par lireCoMarquage)
+var (
+	blocsMarques     []marianne.Brand
+	logosPartenaires []*marianne.Partner
+	layout           marianne.Layout
+)
+
+// avecCoMarquage indique si le logo est en co-marquage (avec d'autres blocs-marques
+// ou des logos partenaires)
+func avecCoMarquage() bool {
+	return len(coMarques) > 0 || len(partenaires) > 0
+}
+
+// lireCoMarquage lit les blocs-marques (le premier est celui de -i et -d), les logos
+// partenaires et la disposition du co-marquage
+func lireCoMarquage() (err error) {
+	if layout, err = marianne.ParseLayout(disposition); err != nil {
+		return err
+	}
+	blocsMarques, logosPartenaires = nil, nil
+	if !avecCoMarquage() {
+		return nil
+	}
+	blocsMarques = []marianne.Brand{{Institution: institution, Direction: direction}}
+	// chaque valeur peut contenir plusieurs blocs-marques séparés par des points-virgules
+	for _, v := range coMarques {
+		for _, b := range strings.Split(v, ";") {
+			if b = strings.TrimSpace(b); b == "" {
+				continue
+			}
+			id := strings.SplitN(b, "|", 2)
+			bm := marianne.Brand{Institution: strings.TrimSpace(id[0])}
+			if len(id) == 2 {
+				bm.Direction = strings.TrimSpace(id[1])
+			}
+			blocsMarques = append(blocsMarques, bm)
+		}
+	}
+	for _, name := range partenaires {
+		f, err := os.Open(name)
+		if err != nil {
+			return ioError("lecture du logo partenaire", err)
+		}
+		p, err := marianne.ParsePartnerSVG(f)
+		f.Close()
+		if err != nil {
+			return &marianne.Error{Kind: marianne.ParameterError, Op: "fichier " + name, Err: err}
+		}
+		logosPartenaires = append(logosPartenaires, p)
+		// les couleurs des partenaires sont gardées dans les PNG et GIF
+		opts.ExtraColors = append(opts.ExtraColors, p.Colors()...)
+	}
+	return nil
+}
+
+// renderLogo dessine le logo avec les options opts, en co-marquage si nécessaire
+func renderLogo() (*canvas.Canvas, error) {
+	if avecCoMarquage() {
+		return marianne.RenderCoBrand(opts, blocsMarques, logosPartenaires, layout)
+	}
+	return marianne.Render(opts)
+}
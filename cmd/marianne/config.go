@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	flag "github.com/spf13/pflag" // pour les paramètres en ligne de commande
+
+	"github.com/kpym/marianne" // la génération du logo
+	"gopkg.in/yaml.v2"         // pour les fichiers de configuration en YAML
+)
+
+// le fichier de configuration utilisé s'il est présent dans le dossier courant
+// et qu'aucun n'est donné avec --config
+const configParDefaut = "marianne.yaml"
+
+// les paramètres qui ne peuvent pas être donnés dans un fichier de configuration
+var horsConfig = map[string]bool{"config": true, "profil": true, "print-config": true, "aide": true, "strict": true}
+
+// lit le fichier de configuration name : les valeurs communes et celles des profils
+func readConfig(name string) (communes map[string]interface{}, profils map[string]map[string]interface{}, err error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, nil, ioError("lecture du fichier de configuration", err)
+	}
+	if err = yaml.Unmarshal(data, &communes); err != nil {
+		return nil, nil, &marianne.Error{Kind: marianne.ParameterError, Op: "fichier de configuration " + name, Err: err}
+	}
+	// les profils sont dans la section "profils"
+	if p, ok := communes["profils"]; ok {
+		delete(communes, "profils")
+		out, _ := yaml.Marshal(p)
+		if err = yaml.Unmarshal(out, &profils); err != nil {
+			return nil, nil, &marianne.Error{Kind: marianne.ParameterError, Op: "profils du fichier de configuration " + name, Err: err}
+		}
+	}
+	return communes, profils, nil
+}
+
+// loadConfig complète les paramètres de la ligne de commande avec ceux du fichier de
+// configuration puis ceux du profil choisi : les paramètres de la ligne de commande
+// sont prioritaires sur ceux du profil, eux-mêmes prioritaires sur les valeurs communes
+func loadConfig() error {
+	name := config
+	if name == "" {
+		if _, err := os.Stat(configParDefaut); err != nil {
+			if profil != "" {
+				return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("le profil %q nécessite un fichier de configuration (--config)", profil)}
+			}
+			return nil
+		}
+		name = configParDefaut
+	}
+	communes, profils, err := readConfig(name)
+	if err != nil {
+		return err
+	}
+	log("Configuration : ", name, "\n")
+
+	// les valeurs communes puis celles du profil
+	valeurs := map[string]string{}
+	for k, v := range communes {
+		valeurs[k] = toString(v)
+	}
+	if profil != "" {
+		p, ok := profils[profil]
+		if !ok {
+			var noms []string
+			for n := range profils {
+				noms = append(noms, n)
+			}
+			sort.Strings(noms)
+			return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("profil %q inconnu (profils disponibles : %s)", profil, strings.Join(noms, ", "))}
+		}
+		for k, v := range p {
+			valeurs[k] = toString(v)
+		}
+	}
+
+	// les valeurs sont données aux paramètres non renseignés en ligne de commande
+	for k, v := range valeurs {
+		f := flag.CommandLine.Lookup(k)
+		if f == nil || horsConfig[k] {
+			return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("paramètre inconnu dans %s : %q", name, k)}
+		}
+		if f.Changed {
+			continue
+		}
+		if err := f.Value.Set(v); err != nil {
+			return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("valeur invalide pour %s dans %s : %q", k, name, v)}
+		}
+	}
+
+	return nil
+}
+
+// une couleur d'impression dans le fichier de la table des couleurs
+type couleurImpression struct {
+	// les pourcentages de cyan, magenta, jaune et noir
+	CMJN []float64 `yaml:"cmjn"`
+	// le nom du ton direct (facultatif)
+	Pantone string `yaml:"pantone"`
+}
+
+// readColorTable lit le fichier YAML name des couleurs d'impression, par exemple
+//
+//	"#000091": {cmjn: [100, 70, 0, 0], pantone: "PANTONE 2728 C"}
+//
+// les couleurs du fichier remplacent celles de la charte (les autres sont gardées)
+func readColorTable(name string) (marianne.ColorTable, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, ioError("lecture de la table des couleurs", err)
+	}
+	var couleurs map[string]couleurImpression
+	if err = yaml.UnmarshalStrict(data, &couleurs); err != nil {
+		return nil, &marianne.Error{Kind: marianne.ParameterError, Op: "table des couleurs " + name, Err: err}
+	}
+	table := marianne.DefaultColorTable()
+	for k, v := range couleurs {
+		rgb, err := marianne.ParseColor(k)
+		if err != nil {
+			return nil, err
+		}
+		if len(v.CMJN) != 4 {
+			return nil, &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("table des couleurs %s : %s doit avoir 4 valeurs cmjn (en %%)", name, k)}
+		}
+		for _, x := range v.CMJN {
+			if x < 0 || x > 100 {
+				return nil, &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("table des couleurs %s : les valeurs cmjn de %s doivent être entre 0 et 100", name, k)}
+			}
+		}
+		table[rgb] = marianne.PrintColor{C: v.CMJN[0] / 100, M: v.CMJN[1] / 100, Y: v.CMJN[2] / 100, K: v.CMJN[3] / 100, Spot: v.Pantone}
+	}
+	return table, nil
+}
+
+// printConfig affiche la configuration effective (en YAML) sur la sortie standard
+func printConfig() error {
+	var conf yaml.MapSlice
+	var err error
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		if horsConfig[f.Name] || f.Deprecated != "" || err != nil {
+			return
+		}
+		var v interface{}
+		switch f.Value.Type() {
+		case "bool":
+			v, err = flag.CommandLine.GetBool(f.Name)
+		case "int":
+			v, err = flag.CommandLine.GetInt(f.Name)
+		case "uint":
+			v, err = flag.CommandLine.GetUint(f.Name)
+		case "stringSlice":
+			v, err = flag.CommandLine.GetStringSlice(f.Name)
+		case "uintSlice":
+			v, err = flag.CommandLine.GetUintSlice(f.Name)
+		case "stringArray":
+			// comme dans les fichiers de lot, les valeurs sont séparées par des points-virgules
+			var a []string
+			a, err = flag.CommandLine.GetStringArray(f.Name)
+			v = strings.Join(a, ";")
+		default:
+			v = f.Value.String()
+		}
+		conf = append(conf, yaml.MapItem{Key: f.Name, Value: v})
+	})
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(conf)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
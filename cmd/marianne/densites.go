@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+// la densité maximale des images (les écrans dépassent rarement 3x)
+const maxDensite = 4
+
+// densitesImages retourne les densités des images matricielles (1x seulement sans --densites)
+func densitesImages() []uint {
+	if len(densites) == 0 {
+		return []uint{1}
+	}
+	return densites
+}
+
+// suffixeDensite retourne le suffixe conventionnel de la densité k ("@2x"), vide pour 1x
+func suffixeDensite(k uint) string {
+	if k == 1 {
+		return ""
+	}
+	return fmt.Sprintf("@%dx", k)
+}
+
+// verifierDensites vérifie les densités et que le modèle des noms les distingue
+func verifierDensites() error {
+	for _, k := range densites {
+		if k == 0 || k > maxDensite {
+			return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("densité invalide %d (entre 1 et %d)", k, maxDensite)}
+		}
+	}
+	if len(densites) > 1 && !strings.Contains(modele, "densite}") {
+		return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("avec plusieurs densités le modèle %q doit contenir le champ {densite}", modele)}
+	}
+	return nil
+}
+
+// une source d'un élément <picture> (un format et ses images aux différentes densités)
+type sourceHTML struct {
+	Type   string
+	Srcset string
+}
+
+// un élément <picture> (ou <img> seule s'il n'y a pas de source)
+type pictureHTML struct {
+	Sources       []sourceHTML
+	Src, Srcset   string
+	Width, Height uint
+	Alt           string
+}
+
+// les éléments <picture> des différentes tailles (voir saveSrcset)
+var pictures = template.Must(template.New("picture").Parse(`{{range .}}{{if .Sources}}<picture>
+{{range .Sources}}  <source type="{{.Type}}" srcset="{{.Srcset}}">
+{{end}}  {{end}}<img src="{{.Src}}" srcset="{{.Srcset}}" width="{{.Width}}" height="{{.Height}}" alt="{{.Alt}}">
+{{if .Sources}}</picture>
+{{end}}{{end}}`))
+
+// jeuImages garde les fichiers d'une taille d'image aux différentes densités
+type jeuImages struct {
+	largeur, hauteur uint                // la taille en pixels CSS (à la densité 1x)
+	densites         []uint              // les densités, dans l'ordre des fichiers
+	fichiers         map[string][]string // les fichiers de chaque format (un par densité)
+}
+
+// nouveauJeu crée le jeu d'images de taille l x h (en pixels CSS)
+func nouveauJeu(l, h uint) *jeuImages {
+	return &jeuImages{largeur: l, hauteur: h, fichiers: map[string][]string{}}
+}
+
+// ajouter enregistre le fichier name au format ext (à la dernière densité du jeu)
+func (j *jeuImages) ajouter(ext, name string) {
+	j.fichiers[ext] = append(j.fichiers[ext], name)
+}
+
+// picture retourne l'élément <picture> du jeu : l'image est en PNG (ou à défaut en JPG,
+// GIF ou WebP) et le WebP est proposé en priorité aux navigateurs qui le lisent
+func (j *jeuImages) picture(rel func(string) string, alt string) pictureHTML {
+	srcset := func(ext string) string {
+		var l []string
+		for i, f := range j.fichiers[ext] {
+			l = append(l, fmt.Sprintf("%s %dx", rel(f), j.densites[i]))
+		}
+		return strings.Join(l, ", ")
+	}
+	p := pictureHTML{Width: j.largeur, Height: j.hauteur, Alt: alt}
+	for _, ext := range []string{"png", "jpg", "gif", "webp"} {
+		if f := j.fichiers[ext]; len(f) > 0 {
+			p.Src, p.Srcset = rel(f[0]), srcset(ext)
+			if ext != "webp" && len(j.fichiers["webp"]) > 0 {
+				p.Sources = append(p.Sources, sourceHTML{marianne.MimeType("webp"), srcset("webp")})
+			}
+			break
+		}
+	}
+	return p
+}
+
+// saveSrcset enregistre dans le fichier name les éléments <picture> des jeux d'images
+// (les chemins des images sont relatifs au dossier du fichier)
+func saveSrcset(name string, jeux []*jeuImages) error {
+	rel := func(f string) string {
+		if r, err := filepath.Rel(filepath.Dir(name), f); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return filepath.ToSlash(f)
+	}
+	var ps []pictureHTML
+	for _, j := range jeux {
+		ps = append(ps, j.picture(rel, opts.Title()))
+	}
+	return saveFile(name, func(w io.Writer) error {
+		if err := pictures.Execute(w, ps); err != nil {
+			return ioError("écriture des balises <picture>", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+// saveLetterhead enregistre le papier à en-tête A4 en PDF et en SVG
+// (les noms des fichiers sont construits avec le modèle des fichiers vectoriels)
+func saveLetterhead() error {
+	log("\nPapier à en-tête :\n")
+	c, err := marianne.RenderLetterhead(opts, piedDePage)
+	if err != nil {
+		return err
+	}
+	var ch = champs{"nom": nom + "_en-tete"}
+	if opts.Variant != marianne.VariantPositive {
+		ch["variante"] = string(opts.Variant)
+	}
+	if opts.ColorMode != marianne.ColorModeColor {
+		ch["mode"] = string(opts.ColorMode)
+	}
+	for _, ext := range []string{"pdf", "svg"} {
+		ch["ext"] = ext
+		if err := saveFile(fileName(modeleVect, ch), func(w io.Writer) error {
+			return marianne.EncodePage(w, c, ext, opts)
+		}); err != nil {
+			return err
+		}
+		log(strings.ToUpper(ext) + " fait.\n")
+	}
+	if top, err := marianne.LetterheadTop(opts); err == nil {
+		log(fmt.Sprintf("Le corps de la lettre commence à %.0f mm du haut, avec des marges de %.0f mm.\n", top/100, marianne.LetterheadMargin/100))
+	}
+	return nil
+}
+
+// saveBusinessCard enregistre la carte de visite en PDF (recto verso)
+// (le nom du fichier est construit avec le modèle des fichiers vectoriels)
+func saveBusinessCard() error {
+	log("\nCarte de visite :\n")
+	a := marianne.Agent{Name: agent, Title: fonction, Phone: telephone, Address: adresse, Email: courriel}
+	pages, err := marianne.RenderBusinessCard(opts, a)
+	if err != nil {
+		return err
+	}
+	var ch = champs{"nom": nom + "_carte", "ext": "pdf"}
+	if opts.Variant != marianne.VariantPositive {
+		ch["variante"] = string(opts.Variant)
+	}
+	if opts.ColorMode != marianne.ColorModeColor {
+		ch["mode"] = string(opts.ColorMode)
+	}
+	if err := saveFile(fileName(modeleVect, ch), func(w io.Writer) error {
+		return marianne.EncodePages(w, pages, opts)
+	}); err != nil {
+		return err
+	}
+	log("PDF fait.\n")
+	return nil
+}
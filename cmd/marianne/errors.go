@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+// les codes de sortie du programme en fonction de la nature de l'erreur
+const (
+	exitInconnue  = 1 // erreur inattendue
+	exitParametre = 2 // paramètre invalide (format inconnu, hauteur nulle...)
+	exitPolice    = 3 // la police Marianne n'a pas pu être chargée
+	exitES        = 4 // erreur de lecture ou d'écriture (disque plein, droits...)
+	exitEncodage  = 5 // erreur lors de l'encodage d'un des formats
+	exitCharte    = 6 // le logo n'est pas conforme à la charte (marianne verifier --strict)
+)
+
+// exitCode retourne le code de sortie correspondant à l'erreur err
+func exitCode(err error) int {
+	switch marianne.KindOf(err) {
+	case marianne.ParameterError:
+		return exitParametre
+	case marianne.FontError:
+		return exitPolice
+	case marianne.IOError:
+		return exitES
+	case marianne.EncodingError:
+		return exitEncodage
+	case marianne.CharterError:
+		return exitCharte
+	}
+	return exitInconnue
+}
+
+// quelques causes fréquentes d'erreurs système traduites en français
+var causes = []struct {
+	err error
+	msg string
+}{
+	{syscall.ENOSPC, "le disque est plein"},
+	{os.ErrPermission, "permission refusée"},
+	{os.ErrNotExist, "fichier ou dossier inexistant"},
+	{os.ErrExist, "le fichier existe déjà"},
+}
+
+// cause retourne (si possible) la cause de l'erreur en français
+func cause(err error) string {
+	for _, c := range causes {
+		if errors.Is(err, c.err) {
+			return c.msg
+		}
+	}
+	return ""
+}
+
+// fatal affiche l'erreur en français (même en mode silence) et quitte le programme
+// avec le code de sortie correspondant
+func fatal(err error) {
+	var msg = fmt.Sprintf("ERREUR (%s) : %v", marianne.KindOf(err), err)
+	if c := cause(err); c != "" {
+		msg += " (" + c + ")"
+	}
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(exitCode(err))
+}
+
+// ioError crée une erreur d'entrée/sortie pour l'opération op
+func ioError(op string, err error) error {
+	return &marianne.Error{Kind: marianne.IOError, Op: op, Err: err}
+}
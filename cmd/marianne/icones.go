@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flag "github.com/spf13/pflag" // pour les paramètres en ligne de commande
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+// une icône du jeu d'icônes
+type icone struct {
+	nom    string  // le nom du fichier
+	taille uint    // la taille en pixels
+	marge  float64 // la marge de chaque côté de la Marianne (en fraction de la taille)
+	opaque bool    // toujours sur fond opaque (même avec --transparent)
+	usage  string  // l'usage dans le manifeste ("maskable" pour les icônes adaptatives d'Android)
+}
+
+var (
+	// les tailles des images du favicon.ico
+	tailleFavicon = []uint{16, 32, 48}
+	// les icônes PNG : iOS remplit la transparence en noir, et les icônes adaptatives
+	// d'Android doivent garder la Marianne dans le cercle central (80 % de la taille)
+	icones = []icone{
+		{"apple-touch-icon.png", 180, 0.1, true, ""},
+		{"icon-192.png", 192, 0.05, false, "any"},
+		{"icon-512.png", 512, 0.05, false, "any"},
+		{"icon-192-maskable.png", 192, 0.15, true, "maskable"},
+		{"icon-512-maskable.png", 512, 0.15, true, "maskable"},
+	}
+)
+
+// une icône du manifeste
+type iconeManifeste struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes"`
+	Type    string `json:"type"`
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// le manifeste de l'application web (site.webmanifest)
+type manifeste struct {
+	Name            string           `json:"name"`
+	ShortName       string           `json:"short_name"`
+	Icons           []iconeManifeste `json:"icons"`
+	ThemeColor      string           `json:"theme_color"`
+	BackgroundColor string           `json:"background_color"`
+	Display         string           `json:"display"`
+}
+
+// les balises à mettre dans le <head> des pages du site
+var balises = template.Must(template.New("icones").Parse(`<link rel="icon" href="{{.}}favicon.ico" sizes="any">
+<link rel="icon" href="{{.}}favicon.svg" type="image/svg+xml">
+<link rel="apple-touch-icon" href="{{.}}apple-touch-icon.png">
+<link rel="manifest" href="{{.}}site.webmanifest">
+`))
+
+// AideIcones affiche l'aide de la commande icones
+func AideIcones(fs *flag.FlagSet) func() {
+	return func() {
+		var out = fs.Output()
+		fmt.Fprintf(out, "marianne icones (version: %s)\n\n", version)
+		fmt.Fprintf(out, "Génère les favicons et les icônes d'applications à partir de la Marianne seule :\n")
+		fmt.Fprintf(out, "  favicon.ico (16, 32 et 48 pixels), favicon.svg, apple-touch-icon.png,\n")
+		fmt.Fprintf(out, "  icon-192.png, icon-512.png, icon-192-maskable.png, icon-512-maskable.png,\n")
+		fmt.Fprintf(out, "  site.webmanifest et icones.html (les balises <link> à mettre dans les pages).\n")
+		fmt.Fprintf(out, "Paramètres disponibles:\n\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(out, "\n")
+	}
+}
+
+// genererIcones lance la commande `marianne icones`
+func genererIcones(args []string) {
+	var (
+		nomSite  string
+		nomCourt string
+		chemin   string
+		theme    string
+	)
+	fs := flag.NewFlagSet("marianne icones", flag.ContinueOnError)
+	fs.StringVar(&dossier, "dossier", ".", "Le dossier dans lequel les fichiers sont enregistrés (créé si nécessaire).")
+	fs.StringVar(&nomSite, "nom-du-site", "République française", "Le nom du site (dans le manifeste).")
+	fs.StringVar(&nomCourt, "nom-court", "", "Le nom court du site, sous l'icône des applications (par défaut le nom du site).")
+	fs.StringVar(&chemin, "chemin", "/", "Le chemin (ou l'URL) des icônes sur le site.")
+	fs.StringVar(&theme, "couleur-theme", marianne.FormatColor(marianne.BleuFrance), "La couleur du thème (dans le manifeste).")
+	fs.BoolVar(&transparent, "transparent", false, "Favicons et icônes 192 et 512 sur fond transparent (les autres restent sur fond opaque).")
+	fs.StringVar(&variante, "variante", "positif", "La variante de la Marianne : positif (en couleurs sur fond blanc) ou negatif (en blanc pour les fonds sombres).")
+	fs.StringVar(&fond, "fond", marianne.FormatColor(marianne.BleuFrance), "La couleur du fond de la variante négative.")
+	fs.StringVar(&mode, "mode", "couleur", "Le mode de couleurs : couleur, gris (niveaux de gris) ou mono (une seule encre).")
+	fs.StringVar(&encre, "encre", "#000000", "La couleur de l'encre du mode mono.")
+	fs.BoolVarP(&silence, "silence", "q", false, "N'imprime rien.")
+	fs.BoolVarP(&aide, "aide", "h", false, "Imprime ce message d'aide.")
+	fs.SortFlags = false
+	fs.SetOutput(FrenchTranslator{os.Stderr})
+	fs.Usage = AideIcones(fs)
+
+	err = fs.Parse(args)
+	if aide || err != nil {
+		fs.Usage()
+		if err != nil {
+			fmt.Fprintln(fs.Output(), "ERREUR : ", err)
+			os.Exit(exitParametre)
+		}
+		os.Exit(0)
+	}
+	if silence {
+		log = func(msg ...interface{}) {}
+	}
+
+	// les options de la Marianne
+	o := marianne.DefaultOptions()
+	o.Transparent = transparent
+	if o.Variant, err = marianne.ParseVariant(variante); err != nil {
+		fatal(err)
+	}
+	if o.Background, err = marianne.ParseColor(fond); err != nil {
+		fatal(err)
+	}
+	if o.ColorMode, err = marianne.ParseColorMode(mode); err != nil {
+		fatal(err)
+	}
+	if o.Ink, err = marianne.ParseColor(encre); err != nil {
+		fatal(err)
+	}
+	couleurTheme, err := marianne.ParseColor(theme)
+	if err != nil {
+		fatal(err)
+	}
+	if nomCourt == "" {
+		nomCourt = nomSite
+	}
+	if !strings.HasSuffix(chemin, "/") {
+		chemin += "/"
+	}
+
+	if err = ecrireIcones(o, manifeste{
+		Name:            nomSite,
+		ShortName:       nomCourt,
+		ThemeColor:      marianne.FormatColor(couleurTheme),
+		BackgroundColor: marianne.FormatColor(o.BackgroundColor()),
+		Display:         "standalone",
+	}, chemin); err != nil {
+		log("\n")
+		fatal(err)
+	}
+}
+
+// ecrireIcones enregistre toutes les icônes, le manifeste m (complété avec les icônes)
+// et les balises HTML dans le dossier
+func ecrireIcones(o marianne.Options, m manifeste, chemin string) error {
+	nomFichier := func(nom string) string { return filepath.Join(dossier, nom) }
+
+	// le favicon : la Marianne sur toute la largeur, en SVG et en ICO multi-résolutions
+	log("Favicon ...")
+	c := marianne.RenderSymbol(o, 0)
+	if err := saveFile(nomFichier("favicon.svg"), func(w io.Writer) error {
+		return marianne.EncodeCanvas(w, c, "svg", o)
+	}); err != nil {
+		return err
+	}
+	log(".svg.")
+	var imgs []image.Image
+	for _, t := range tailleFavicon {
+		imgs = append(imgs, marianne.CanvasToRGBAImg(c, t))
+	}
+	if err := saveFile(nomFichier("favicon.ico"), func(w io.Writer) error {
+		return marianne.EncodeICO(w, imgs)
+	}); err != nil {
+		return err
+	}
+	log(".ico. Fait.\n")
+
+	// les icônes des applications (en PNG avec toute la couche alpha)
+	for _, ic := range icones {
+		log(ic.nom, " ...")
+		oi := o
+		oi.Transparent = o.Transparent && !ic.opaque
+		img := marianne.CanvasToRGBAImg(marianne.RenderSymbol(oi, ic.marge), ic.taille)
+		if err := saveFile(nomFichier(ic.nom), func(w io.Writer) error {
+			// avec Transparent le PNG garde toutes les couleurs (sans réduction à 8 ou 16)
+			return marianne.EncodeImage(w, img, "png", marianne.Options{Transparent: true})
+		}); err != nil {
+			return err
+		}
+		log(" fait.\n")
+		if ic.usage != "" {
+			m.Icons = append(m.Icons, iconeManifeste{
+				Src:     chemin + ic.nom,
+				Sizes:   fmt.Sprintf("%dx%d", ic.taille, ic.taille),
+				Type:    "image/png",
+				Purpose: ic.usage,
+			})
+		}
+	}
+
+	// le manifeste et les balises HTML
+	if err := saveFile(nomFichier("site.webmanifest"), func(w io.Writer) error {
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return &marianne.Error{Kind: marianne.EncodingError, Op: "encodage du manifeste", Err: err}
+		}
+		if _, err = w.Write(append(data, '\n')); err != nil {
+			return ioError("écriture du manifeste", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := saveFile(nomFichier("icones.html"), func(w io.Writer) error {
+		if err := balises.Execute(w, chemin); err != nil {
+			return ioError("écriture des balises HTML", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	log("site.webmanifest et icones.html faits.\n")
+	return nil
+}
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	flag "github.com/spf13/pflag" // pour les paramètres en ligne de commande
+
+	"github.com/kpym/marianne"   // la génération du logo
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// le nom du logo pour écrire sur la sortie standard
+const stdout = "-"
+
+// quelques variables globales
+var (
+	// la version du logiciel (remplacée lors de la compilation)
+	version = "--"
+
+	// une variable temporaire d'erreur
+	err error
+)
+
+// Imprimer des messages (si pas en mode silence)
+var log = func(msg ...interface{}) {
+	fmt.Fprint(os.Stderr, msg...)
+}
+
+// Aide affiche l'aide d'utilisation
+func Aide() {
+	var out = flag.CommandLine.Output()
+	fmt.Fprintf(out, "marianne (version: %s)\n\n", version)
+	if verification {
+		fmt.Fprintf(out, "marianne verifier signale les écarts à la charte du logo décrit par les paramètres, sans le générer.\nParamètres disponibles:\n\n")
+	} else {
+		fmt.Fprintf(out, "Ce programme génère le logo de l'institution.\nParamètres disponibles:\n\n")
+	}
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nPour générer les logos à la demande via HTTP : marianne serve -h\n")
+	fmt.Fprintf(out, "Pour générer les favicons et les icônes d'applications : marianne icones -h\n")
+	fmt.Fprintf(out, "Pour vérifier la conformité du logo à la charte : marianne verifier -h\n\n")
+}
+
+// les flags (pour la description voir SetParameters plus bas)
+var (
+	nom           string
+	dossier       string
+	modele        string
+	modeleVect    string
+	institution   string
+	direction     string
+	formats       []string
+	hauteurs      []uint
+	largeurs      []uint
+	tailles       []string
+	dpi           float64
+	densites      []uint
+	surech        uint
+	filtre        string
+	alignement    bool
+	avecMarges    bool
+	sansMarges    bool
+	pourSignature bool
+	agent         string
+	fonction      string
+	telephone     string
+	adresse       string
+	courriel      string
+	urlImage      string
+	papierEnTete  bool
+	carteDeVisite bool
+	coMarques     []string
+	partenaires   []string
+	disposition   string
+	piedDePage    string
+	eol           string
+	lignes        int
+	largeurTexte  float64
+	jpgq          int
+	webpq         int
+	col16         bool
+	couleurs      int
+	tramage       bool
+	optimPNG      int
+	transparent   bool
+	variante      string
+	fond          string
+	mode          string
+	encre         string
+	impression    bool
+	tonsDirects   bool
+	tableCouleurs string
+	normePDF      string
+	icc           string
+	silence       bool
+	aide          bool
+	lot           string
+	config        string
+	profil        string
+	printConf     bool
+
+	// les options du logo (construites à partir des flags)
+	opts marianne.Options
+)
+
+// declareFlags déclare les flags (c.-à-d. les paramètres de la ligne de commande)
+func declareFlags() {
+	flag.StringVarP(&nom, "nom-du-logo", "o", "logo", "Le nom du logo = le début des noms des fichiers générés ('-' pour écrire un seul fichier sur la sortie standard).")
+	flag.StringVar(&dossier, "dossier", ".", "Le dossier dans lequel les fichiers sont enregistrés (créé si nécessaire).")
+	flag.StringVar(&modele, "modele", modeleParDefaut, "Le modèle des noms des fichiers PNG, GIF, JPG et WebP (champs : {nom}, {szp}, {hauteur}, {largeur}, {dpi}, {densite}, {couleurs}, {mode}, {variante}, {ext}).")
+	flag.StringVar(&modeleVect, "modele-vectoriel", modeleVectorielParDefaut, "Le modèle des noms des fichiers SVG, PDF et EPS.")
+	flag.StringVarP(&institution, "institution", "i", "RÉPUBLIQUE\\FRANÇAISE", "Le nom du ministère, ambassade...")
+	flag.StringVarP(&direction, "direction", "d", "", "Intitulé de direction, service ou délégation interministérielles.")
+	flag.StringSliceVarP(&formats, "format", "f", nil, "Le(s) format(s) parmi SVG, PDF, EPS, PNG, GIF, JPG et WebP. (par défaut SVG, ou PNG pour signature)")
+	flag.UintSliceVarP(&hauteurs, "hauteur", "t", nil, "La (ou les) hauteur(s) pour les logos en PNG, GIF, JPG et WebP. (par défaut 700, ou 100 pour signature)")
+	flag.UintSliceVar(&largeurs, "largeur", nil, "La (ou les) largeur(s) en pixels pour les logos en PNG, GIF, JPG et WebP (la hauteur suit les proportions du logo).")
+	flag.StringSliceVar(&tailles, "taille", nil, "La (ou les) largeur(s) imprimée(s) des logos en PNG, GIF, JPG et WebP (en mm, cm, in ou pt, par exemple 30mm), à la résolution --dpi.")
+	flag.Float64Var(&dpi, "dpi", 0, "La résolution (en points par pouce) enregistrée dans les PNG et JPG. (par défaut 300 avec --taille)")
+	flag.UintVar(&surech, "surechantillonnage", 0, "Le facteur de suréchantillonnage des images (1 à 8). (par défaut 2 en dessous de 700 pixels de haut, 1 au-delà)")
+	flag.StringVar(&filtre, "filtre", "lanczos", "Le filtre qui réduit les images suréchantillonnées : lanczos, mitchell, boite ou aucun (sans suréchantillonnage).")
+	flag.BoolVar(&alignement, "alignement-pixels", false, "Aligne le trait séparateur et les lignes de base des textes sur les pixels (plus nets aux petites tailles).")
+	flag.UintSliceVar(&densites, "densites", nil, "Les densités d'écran des images, par exemple 1,2,3 pour les suffixes @2x et @3x, avec les balises <picture> dans .picture.html.")
+	flag.BoolVarP(&avecMarges, "avec-marges", "M", false, "Avec zone de protection autour du logo. Ce paramètre est compatible avec -sans-marges.")
+	flag.BoolVarP(&sansMarges, "sans-marges", "m", false, "Sans zone de protection autour du logo ('_szp' est rajouté aux noms des fichiers).")
+	flag.BoolVarP(&pourSignature, "pour-signature", "g", false, "Le logo est destiné à une signature mail.")
+	flag.StringVar(&agent, "agent", "", "Le prénom et le nom de l'agent : génère aussi la signature mail en HTML et en texte.")
+	flag.StringVar(&fonction, "fonction", "", "La fonction de l'agent (signature mail et carte de visite).")
+	flag.StringVar(&telephone, "telephone", "", "Le numéro de téléphone de l'agent (signature mail et carte de visite).")
+	flag.StringVar(&adresse, "adresse", "", "L'adresse postale de l'agent, avec le passage à la ligne de --eol (signature mail et carte de visite).")
+	flag.StringVar(&courriel, "courriel", "", "L'adresse électronique de l'agent (signature mail et carte de visite).")
+	flag.BoolVar(&papierEnTete, "papier-en-tete", false, "Génère aussi le papier à en-tête A4 (en PDF et SVG) avec le bloc-marque en haut à gauche.")
+	flag.StringVar(&piedDePage, "pied-de-page", "", "Le pied de page du papier à en-tête (l'adresse...), avec le passage à la ligne de --eol.")
+	flag.BoolVar(&carteDeVisite, "carte-de-visite", false, "Génère aussi la carte de visite 85 x 55 mm (PDF recto verso avec fond perdu et traits de coupe) avec les coordonnées de l'agent.")
+	flag.StringVar(&urlImage, "url-image", "", "L'URL du dossier où sera publié le logo de la signature mail (par défaut le logo est intégré au HTML).")
+	flag.StringArrayVar(&coMarques, "co-marque", nil, "Un autre bloc-marque en co-marquage, sous la forme \"institution|direction\" (plusieurs séparés par des points-virgules).")
+	flag.StringSliceVar(&partenaires, "partenaire", nil, "Le(s) logo(s) partenaire(s) en SVG, ajoutés après les blocs-marques en co-marquage.")
+	flag.StringVar(&disposition, "disposition", "horizontal", "La disposition du co-marquage : horizontal (côte à côte) ou vertical (les uns sous les autres).")
+	flag.StringVar(&eol, "eol", "\\", "Le passage à la ligne, en plus du EOL standard.")
+	flag.IntVar(&lignes, "lignes", 0, "Passe à la ligne automatiquement en équilibrant ce nombre de lignes (l'institution et la direction sans --eol).")
+	flag.Float64Var(&largeurTexte, "largeur-texte", 0, "Passe à la ligne automatiquement au-delà de cette largeur, en hauteurs de capitale (par exemple 12).")
+	flag.IntVar(&jpgq, "qualite-jpg", 100, "La qualité [1-100] des jpeg.")
+	flag.IntVar(&webpq, "qualite-webp", 0, "La qualité [1-100] des WebP avec perte, 0 pour les WebP sans perte.")
+	flag.BoolVar(&transparent, "transparent", false, "Fond transparent (PNG avec couche alpha, GIF avec couleur transparente, SVG, PDF et EPS sans fond blanc).")
+	flag.StringVar(&variante, "variante", "positif", "La variante du logo : positif (en couleurs sur fond blanc) ou negatif (en blanc pour les fonds sombres).")
+	flag.StringVar(&fond, "fond", marianne.FormatColor(marianne.BleuFrance), "La couleur du fond de la variante négative.")
+	flag.StringVar(&mode, "mode", "couleur", "Le mode de couleurs : couleur, gris (niveaux de gris) ou mono (une seule encre).")
+	flag.StringVar(&encre, "encre", "#000000", "La couleur de l'encre du mode mono.")
+	flag.BoolVar(&impression, "impression", false, "Les PDF et EPS sont en couleurs d'impression (CMJN) au lieu de RVB.")
+	flag.BoolVar(&tonsDirects, "tons-directs", false, "Avec --impression, utilise les tons directs (Pantone) pour le bleu et le rouge.")
+	flag.StringVar(&tableCouleurs, "table-couleurs", "", "Le fichier YAML des couleurs d'impression (CMJN et Pantone) qui remplacent celles de la charte.")
+	flag.StringVar(&normePDF, "norme-pdf", "", "La norme des PDF : pdfa-2b (archivage) ou pdfx-4 (imprimeurs, toujours en CMJN).")
+	flag.StringVar(&icc, "icc", "", "Le profil ICC de l'intention de sortie des PDF/A et PDF/X (obligatoire pour le PDF/X, généré en sRGB ou CMJN par défaut pour le PDF/A).")
+	flag.BoolVar(&col16, "seize-couleurs", false, "Enregistre les PNG et les GIF en 16 couleurs, sinon c'est en 8.")
+	flag.CommandLine.MarkDeprecated("seize-couleurs", "utilisez --couleurs 16")
+	flag.IntVar(&couleurs, "couleurs", 0, "Le nombre de couleurs [2-256] des PNG, GIF et WebP sans perte, avec une palette adaptée à l'image. (par défaut les 8 couleurs du logo)")
+	flag.BoolVar(&tramage, "tramage", false, "Diffuse l'erreur de couleur (tramage de Floyd-Steinberg) dans les PNG, GIF et WebP en couleurs indexées.")
+	flag.IntVar(&optimPNG, "optimisation-png", 0, "L'optimisation sans perte des PNG : 0 (aucune), 1 (compression maximale) ou 2 (essaie aussi tous les filtres et les réductions de couleurs).")
+	flag.StringVarP(&lot, "lot", "l", "", "Génère tous les logos décrits dans un fichier CSV, JSON ou YAML (les autres paramètres servent de valeurs par défaut).")
+	flag.StringVarP(&config, "config", "c", "", "Le fichier de configuration YAML (par défaut "+configParDefaut+" s'il est présent).")
+	flag.StringVarP(&profil, "profil", "p", "", "Le profil du fichier de configuration à utiliser.")
+	flag.BoolVar(&printConf, "print-config", false, "Imprime la configuration effective (en YAML) sans générer de logo.")
+	flag.BoolVarP(&silence, "silence", "q", false, "N'imprime rien.")
+	flag.BoolVarP(&aide, "aide", "h", false, "Imprime ce message d'aide.")
+}
+
+// SetParameters récupération des paramètre à partir de la ligne de commande, puis
+// retourne `formatstr` qui contient la liste des format sous la forme "svg,png..."
+func SetParameters() (formatstr string) {
+	declareFlags()
+	// garde l'ordre des paramètres dans l'aide
+	flag.CommandLine.SortFlags = false
+	// installe la traduction des messages en français
+	flag.CommandLine.SetOutput(FrenchTranslator{flag.CommandLine.Output()})
+	// le message d'aide
+	flag.Usage = Aide
+	// en cas d'erreur ne pas afficher l'erreur une deuxième fois
+	flag.CommandLine.Init("marianne", flag.ContinueOnError)
+
+	// récupère les flags
+	err = flag.CommandLine.Parse(os.Args[1:])
+	// affiche l'aide si demandé ou si erreur de paramètre
+	if aide || err != nil {
+		flag.Usage()
+		if err != nil {
+			fmt.Fprintln(flag.CommandLine.Output(), "ERREUR : ", err)
+			os.Exit(2)
+		} else {
+			os.Exit(0)
+		}
+	}
+
+	// complète avec le fichier de configuration
+	if printConf {
+		log = func(msg ...interface{}) {}
+	}
+	if err = loadConfig(); err != nil {
+		fatal(err)
+	}
+
+	// silence ?
+	if silence || printConf {
+		log = func(msg ...interface{}) {}
+	}
+
+	// en mode lot les paramètres sont complétés pour chaque entrée
+	if lot != "" && !printConf {
+		return ""
+	}
+
+	formatstr, err = completeParameters()
+	if err != nil {
+		fatal(err)
+	}
+
+	// affiche la configuration effective ?
+	if printConf {
+		if err = printConfig(); err != nil {
+			fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	return // formatstr
+}
+
+// completeParameters complète les paramètres non renseignés par leurs valeurs par défaut,
+// vérifie les formats et les hauteurs, puis retourne `formatstr` (voir SetParameters)
+func completeParameters() (formatstr string, err error) {
+	// au moins une des versions doit être présente (avec marges par défaut)
+	if !sansMarges && !avecMarges {
+		if pourSignature {
+			sansMarges = true
+		} else {
+			avecMarges = true
+		}
+	}
+
+	// le format par défaut
+	if len(formats) == 0 {
+		if pourSignature {
+			formats = []string{"PNG"}
+		} else {
+			formats = []string{"SVG"}
+		}
+	}
+
+	// la hauteur par défaut (si aucune taille d'image n'est précisée)
+	if len(hauteurs) == 0 && len(largeurs) == 0 && len(tailles) == 0 {
+		if pourSignature {
+			hauteurs = []uint{100}
+		} else {
+			hauteurs = []uint{700}
+		}
+	}
+
+	// la signature mail utilise le logo en PNG
+	if avecSignature() && !strings.Contains(strings.ToLower(strings.Join(formats, ",")), "png") {
+		formats = append(append([]string{}, formats...), "png")
+	}
+
+	// normalisation et vérification des formats
+	formatstr = strings.ToLower(strings.Join(formats, ","))
+	for _, f := range strings.Split(formatstr, ",") {
+		if !marianne.IsFormat(f) {
+			return "", &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("format inconnu %q (formats possibles : SVG, PDF, EPS, PNG, GIF, JPG et WebP)", f)}
+		}
+	}
+	// les tailles des images
+	if dimensions, err = lireDimensions(); err != nil {
+		return "", err
+	}
+	if err = verifierDensites(); err != nil {
+		return "", err
+	}
+
+	// sur la sortie standard on ne peut écrire qu'un seul fichier
+	if nom == stdout {
+		var n int
+		for _, f := range marianne.Formats {
+			if strings.Contains(formatstr, f) || (f == "jpg" && strings.Contains(formatstr, "jpeg")) {
+				n++
+				if marianne.IsRaster(f) && len(dimensions)*len(densitesImages()) > 1 {
+					n++
+				}
+			}
+		}
+		if n > 1 || (avecMarges && sansMarges) || avecSignature() || papierEnTete || carteDeVisite {
+			return "", &marianne.Error{Kind: marianne.ParameterError, Op: "avec -o - un seul fichier peut être écrit (un format, une hauteur, une densité, avec ou sans marges, sans signature, papier à en-tête ni carte de visite)"}
+		}
+	}
+
+	// vérification des modèles de noms de fichiers
+	for _, m := range []string{modele, modeleVect} {
+		if err := checkTemplate(m); err != nil {
+			return "", err
+		}
+	}
+
+	// la variante et la couleur du fond
+	v, err := marianne.ParseVariant(variante)
+	if err != nil {
+		return "", err
+	}
+	bg, err := marianne.ParseColor(fond)
+	if err != nil {
+		return "", err
+	}
+	// le mode de couleurs et l'encre
+	cm, err := marianne.ParseColorMode(mode)
+	if err != nil {
+		return "", err
+	}
+	ink, err := marianne.ParseColor(encre)
+	if err != nil {
+		return "", err
+	}
+
+	// les couleurs d'impression
+	var table marianne.ColorTable
+	if tableCouleurs != "" {
+		if table, err = readColorTable(tableCouleurs); err != nil {
+			return "", err
+		}
+	}
+
+	// la norme des PDF et le profil ICC
+	norme, err := marianne.ParsePDFStandard(normePDF)
+	if err != nil {
+		return "", err
+	}
+	var profilICC []byte
+	if icc != "" {
+		if profilICC, err = ioutil.ReadFile(icc); err != nil {
+			return "", ioError("lecture du profil ICC", err)
+		}
+	}
+
+	// le rééchantillonnage des images
+	filtreImages, err := marianne.ParseFilter(filtre)
+	if err != nil {
+		return "", err
+	}
+
+	if couleurs < 0 || couleurs == 1 || couleurs > 256 {
+		return "", &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("nombre de couleurs invalide %d (entre 2 et 256)", couleurs)}
+	}
+	if optimPNG < marianne.PNGOptimizationNone || optimPNG > marianne.PNGOptimizationMax {
+		return "", &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("niveau d'optimisation des PNG invalide %d (0, 1 ou 2)", optimPNG)}
+	}
+
+	if lignes < 0 || largeurTexte < 0 {
+		return "", &marianne.Error{Kind: marianne.ParameterError, Op: "--lignes et --largeur-texte ne peuvent pas être négatifs"}
+	}
+	if jpgq < 1 {
+		jpgq = 1
+	} else if jpgq > 100 {
+		jpgq = 100
+	}
+	if webpq < 0 {
+		webpq = 0
+	} else if webpq > 100 {
+		webpq = 100
+	}
+
+	// les options du logo
+	opts = marianne.Options{
+		Institution:     institution,
+		Direction:       direction,
+		Signature:       pourSignature,
+		EOL:             eol,
+		Wrap:            marianne.Wrap{MaxWidth: largeurTexte, MaxLines: lignes},
+		JPEGQuality:     jpgq,
+		WebPQuality:     webpq,
+		DPI:             dpi,
+		Supersampling:   surech,
+		Filter:          filtreImages,
+		Hinting:         alignement,
+		Colors16:        col16,
+		Colors:          couleurs,
+		Dither:          tramage,
+		PNGOptimization: optimPNG,
+		Transparent:     transparent,
+		Variant:         v,
+		Background:      bg,
+		ColorMode:       cm,
+		Ink:             ink,
+		CMYK:            impression || tonsDirects,
+		Spot:            tonsDirects,
+		ColorTable:      table,
+		PDFStandard:     norme,
+		ICCProfile:      profilICC,
+		Creator:         "marianne " + version,
+	}
+
+	// le co-marquage
+	if err := lireCoMarquage(); err != nil {
+		return "", err
+	}
+
+	return formatstr, nil
+}
+
+// SaveRasterImage enregistre l'image dans le fichier name au format ext
+// (avec la taille avant et après optimisation pour les PNG optimisés)
+func SaveRasterImage(img image.Image, name, ext string) error {
+	if ext == "png" && opts.PNGOptimization > marianne.PNGOptimizationNone {
+		return saveOptimizedPNG(img, name)
+	}
+	if err := saveFile(name, func(w io.Writer) error {
+		return marianne.EncodeImage(w, img, ext, opts)
+	}); err != nil {
+		return err
+	}
+	log(".." + ext + ".")
+	return nil
+}
+
+// saveOptimizedPNG enregistre l'image en PNG optimisé dans le fichier name
+func saveOptimizedPNG(img image.Image, name string) error {
+	o := opts
+	o.PNGOptimization = marianne.PNGOptimizationNone
+	var buf bytes.Buffer
+	if err := marianne.EncodeImage(&buf, img, "png", o); err != nil {
+		return err
+	}
+	data, err := marianne.OptimizePNG(buf.Bytes(), opts.PNGOptimization)
+	if err != nil {
+		return err
+	}
+	if err := saveFile(name, func(w io.Writer) error {
+		if _, err := w.Write(data); err != nil {
+			return ioError("écriture du PNG", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	log(fmt.Sprintf("..png (%d → %d octets).", buf.Len(), len(data)))
+	return nil
+}
+
+// enregistre le canevas c au format vectoriel ext (svg, pdf ou eps)
+func saveVectorImage(c *canvas.Canvas, name, ext string) error {
+	if err := saveFile(name, func(w io.Writer) error {
+		return marianne.EncodeCanvas(w, c, ext, opts)
+	}); err != nil {
+		return err
+	}
+	log(strings.ToUpper(ext) + " fait.\n")
+	return nil
+}
+
+// crée le fichier name (et son dossier si nécessaire), y écrit avec write puis le ferme
+// (si name est "-" on écrit sur la sortie standard)
+func saveFile(name string, write func(w io.Writer) error) error {
+	if name == stdout {
+		return write(os.Stdout)
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return ioError("création du dossier", err)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return ioError("création du fichier", err)
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return ioError("écriture du fichier", err)
+	}
+	return nil
+}
+
+// Créer les fichiers : svg, pdf, eps, png, gif, jpg, webp
+// - c : le canvas contenant l'image
+// - zp : chaîne "sans zone de protection" a rajouter au nom ou pas
+func writeImages(c *canvas.Canvas, zp, formats string) error {
+	// les champs communs à tous les noms de fichiers
+	var ch = champs{"nom": nom, "szp": strings.TrimPrefix(zp, "_")}
+	if opts.Variant != marianne.VariantPositive {
+		ch["variante"] = string(opts.Variant)
+	}
+	if opts.ColorMode != marianne.ColorModeColor {
+		ch["mode"] = string(opts.ColorMode)
+	}
+	// le nom du fichier à partir du modèle (ou la sortie standard)
+	fileName := func(modele string, ch champs) string {
+		if nom == stdout {
+			return stdout
+		}
+		return fileName(modele, ch)
+	}
+
+	// Création des SVG, PDF et EPS
+	for _, ext := range []string{"svg", "pdf", "eps"} {
+		if strings.Contains(formats, ext) {
+			ch["ext"] = ext
+			if err := saveVectorImage(c, fileName(modeleVect, ch), ext); err != nil {
+				return err
+			}
+		}
+	}
+
+	doPNG := strings.Contains(formats, "png")
+	doGIF := strings.Contains(formats, "gif")
+	doJPG := strings.Contains(formats, "jpg") || strings.Contains(formats, "jpeg")
+	doWebP := strings.Contains(formats, "webp")
+
+	if doPNG || doGIF || doJPG || doWebP {
+		ch["dpi"] = ""
+		if dpi > 0 {
+			ch["dpi"] = strconv.FormatFloat(dpi, 'f', -1, 64)
+		}
+		// pour chaque taille ...
+		var jeux []*jeuImages
+		for i, d := range dimensions {
+			// la taille en pixels CSS (à la densité 1x)
+			l, h := d.taille(c)
+			ch["largeur"], ch["hauteur"] = fmt.Sprint(l), fmt.Sprint(h)
+			jeu := nouveauJeu(l, h)
+			jeux = append(jeux, jeu)
+			// ... et pour chaque densité
+			for j, k := range densitesImages() {
+				ch["densite"] = suffixeDensite(k)
+				jeu.densites = append(jeu.densites, k)
+				log("Image ", d.description, ch["densite"], ".")
+				// l'image matriciel non compressé
+				img := d.fois(k).image(c)
+				// création du JPG
+				if doJPG {
+					ch["ext"], ch["couleurs"] = "jpg", ""
+					if err := SaveRasterImage(img, fileName(modele, ch), "jpg"); err != nil {
+						return err
+					}
+					jeu.ajouter("jpg", fileName(modele, ch))
+				}
+				// création du WebP (indexé comme les PNG s'il est sans perte et sans transparence)
+				if doWebP {
+					webp := img
+					ch["ext"], ch["couleurs"] = "webp", ""
+					if webpq == 0 && !transparent {
+						webp = marianne.IndexImage(img, opts)
+						ch["couleurs"] = fmt.Sprint(len(webp.(*image.Paletted).Palette))
+					}
+					if err := SaveRasterImage(webp, fileName(modele, ch), "webp"); err != nil {
+						return err
+					}
+					jeu.ajouter("webp", fileName(modele, ch))
+				}
+				// Création des PNG et GIF (en 8 couleurs)
+				if doPNG || doGIF {
+					// avec fond transparent les PNG gardent la couche alpha (et les GIF sont indexés à part)
+					ch["couleurs"] = ""
+					if !transparent {
+						img = marianne.IndexImage(img, opts)
+						ch["couleurs"] = fmt.Sprint(len(img.(*image.Paletted).Palette))
+					}
+					if doPNG {
+						ch["ext"] = "png"
+						name := fileName(modele, ch)
+						if err := SaveRasterImage(img, name, "png"); err != nil {
+							return err
+						}
+						jeu.ajouter("png", name)
+						// la signature mail utilise le logo de la première hauteur
+						if i == 0 && j == 0 && avecSignature() {
+							if err := saveSignature(img, name, ch); err != nil {
+								return err
+							}
+						}
+					}
+					if doGIF {
+						ch["ext"] = "gif"
+						if err := SaveRasterImage(img, fileName(modele, ch), "gif"); err != nil {
+							return err
+						}
+						jeu.ajouter("gif", fileName(modele, ch))
+					}
+				}
+				log(" Fait.\n")
+			}
+		}
+		// les balises <picture> des images aux différentes densités
+		if len(densites) > 0 && nom != stdout {
+			ch["densite"], ch["ext"] = "", "picture.html"
+			if err := saveSrcset(fileName(modeleVect, ch), jeux); err != nil {
+				return err
+			}
+			log("Balises <picture> faites.\n")
+		}
+	}
+
+	return nil
+}
+
+// génère le logo (avec et/ou sans marges) et enregistre les fichiers
+func run(formatstr string) error {
+	if verification {
+		return verifier(formatstr)
+	}
+	if sansMarges {
+		log("Création du logo ...")
+		opts.NoMargins = true
+		c, err := renderLogo()
+		if err != nil {
+			return err
+		}
+		log("fait.\n")
+		log("\nEnregistrement sans marges :\n")
+		if err = writeImages(c, "_szp", formatstr); err != nil {
+			return err
+		}
+	}
+	if avecMarges {
+		log("Création du logo ...")
+		opts.NoMargins = false
+		c, err := renderLogo()
+		if err != nil {
+			return err
+		}
+		log("fait.\n")
+		log("\nEnregistrement avec marges :\n")
+		if err = writeImages(c, "", formatstr); err != nil {
+			return err
+		}
+	}
+	if papierEnTete {
+		if err := saveLetterhead(); err != nil {
+			return err
+		}
+	}
+	if carteDeVisite {
+		return saveBusinessCard()
+	}
+	return nil
+}
+
+func main() {
+	// le mode serveur HTTP
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
+	}
+	// les favicons et les icônes d'applications
+	if len(os.Args) > 1 && os.Args[1] == "icones" {
+		genererIcones(os.Args[2:])
+		return
+	}
+
+	// la vérification de la conformité à la charte (avec les paramètres habituels)
+	if len(os.Args) > 1 && os.Args[1] == "verifier" {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		initVerification()
+	}
+
+	// récpère les paramètres de l'application
+	var formatstr = SetParameters()
+
+	// le mode lot
+	if lot != "" {
+		if err := runBatch(lot); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if err := run(formatstr); err != nil {
+		log("\n")
+		fatal(err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+// les modèles de noms de fichiers par défaut
+const (
+	modeleVectorielParDefaut = "{nom}{_szp}{_variante}{_mode}.{ext}"
+	modeleParDefaut          = "{nom}{_szp}{_variante}{_mode}_{hauteur}{densite}.{ext}"
+)
+
+// un champ d'un modèle de nom : {champ}, ou {_champ} (idem avec "-" ou ".") pour
+// ajouter le séparateur seulement si la valeur du champ n'est pas vide
+var reChamp = regexp.MustCompile(`\{([_.-]?)([^{}]*)\}`)
+
+// les champs disponibles dans les modèles de noms de fichiers
+var nomsChamps = map[string]bool{
+	"nom":      true, // le nom du logo (--nom-du-logo)
+	"szp":      true, // "szp" pour le logo sans zone de protection, vide sinon
+	"hauteur":  true, // la hauteur en pixels (images matricielles)
+	"largeur":  true, // la largeur en pixels (images matricielles)
+	"dpi":      true, // la résolution en points par pouce (si elle est précisée)
+	"densite":  true, // le suffixe de densité d'écran (@2x, @3x), vide pour 1x
+	"couleurs": true, // le nombre de couleurs (PNG et GIF)
+	"mode":     true, // le mode de couleurs (vide pour les couleurs standards)
+	"variante": true, // la variante du logo (vide pour la variante standard)
+	"ext":      true, // l'extension du fichier (svg, png...)
+}
+
+// les valeurs des champs d'un modèle de nom de fichier
+type champs map[string]string
+
+// checkTemplate vérifie que le modèle n'utilise que des champs connus
+func checkTemplate(modele string) error {
+	if strings.TrimSpace(modele) == "" {
+		return &marianne.Error{Kind: marianne.ParameterError, Op: "le modèle de nom de fichier est vide"}
+	}
+	for _, m := range reChamp.FindAllStringSubmatch(modele, -1) {
+		if !nomsChamps[m[2]] {
+			var noms []string
+			for n := range nomsChamps {
+				noms = append(noms, "{"+n+"}")
+			}
+			sort.Strings(noms)
+			return &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("champ inconnu %q dans le modèle %q (champs possibles : %s)", m[0], modele, strings.Join(noms, ", "))}
+		}
+	}
+	return nil
+}
+
+// fileName construit le nom du fichier (dans le dossier de sortie) à partir du modèle
+func fileName(modele string, c champs) string {
+	name := reChamp.ReplaceAllStringFunc(modele, func(m string) string {
+		sub := reChamp.FindStringSubmatch(m)
+		if v := c[sub[2]]; v != "" {
+			return sub[1] + v
+		}
+		return ""
+	})
+	return filepath.Join(dossier, name)
+}
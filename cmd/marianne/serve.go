@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag" // pour les paramètres en ligne de commande
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+// les limites imposées aux requêtes du serveur
+const (
+	maxHauteur = 5000 // la hauteur maximale des images
+	maxTexte   = 500  // la longueur maximale des textes (institution, direction)
+)
+
+// les délais du serveur : pour lire les en-têtes, toute la requête, et écrire la réponse
+// (la génération des grandes images optimisées peut prendre quelques secondes)
+const (
+	delaiEntetes = 10 * time.Second
+	delaiLecture = 30 * time.Second
+	delaiReponse = 2 * time.Minute
+)
+
+// cache garde en mémoire les derniers logos générés (du plus récent au plus ancien),
+// dans la limite de max octets
+type cache struct {
+	sync.Mutex
+	max, size int
+	items     map[string]*list.Element
+	order     *list.List
+}
+
+// une entrée du cache
+type cacheItem struct {
+	key  string
+	data []byte
+}
+
+// newCache crée un cache d'au plus max octets
+func newCache(max int) *cache {
+	return &cache{max: max, items: make(map[string]*list.Element), order: list.New()}
+}
+
+// get retourne le logo correspondant à key (s'il est présent)
+func (c *cache) get(key string) ([]byte, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*cacheItem).data, true
+	}
+	return nil, false
+}
+
+// put ajoute le logo data au cache (en supprimant les plus anciens si nécessaire) ;
+// un logo plus gros que tout le cache n'est pas gardé
+func (c *cache) put(key string, data []byte) {
+	c.Lock()
+	defer c.Unlock()
+	if c.max <= 0 || len(data) > c.max {
+		return
+	}
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		item := e.Value.(*cacheItem)
+		c.size += len(data) - len(item.data)
+		item.data = data
+	} else {
+		c.items[key] = c.order.PushFront(&cacheItem{key, data})
+		c.size += len(data)
+	}
+	for c.size > c.max {
+		e := c.order.Back()
+		c.order.Remove(e)
+		item := e.Value.(*cacheItem)
+		delete(c.items, item.key)
+		c.size -= len(item.data)
+	}
+}
+
+// logoServer est le serveur HTTP qui génère les logos à la demande
+type logoServer struct {
+	cache *cache
+}
+
+// récupère un booléen dans la requête ("1", "true", "on"... comme strconv.ParseBool)
+func queryBool(q url.Values, name string) (bool, error) {
+	v := q.Get(name)
+	if v == "" {
+		return false, nil
+	}
+	if v == "on" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("valeur invalide pour %s : %q", name, v)}
+	}
+	return b, nil
+}
+
+// récupère un entier dans la requête (def s'il est absent)
+func queryInt(q url.Values, name string, def, min, max int) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("valeur invalide pour %s : %q (entre %d et %d)", name, v, min, max)}
+	}
+	return n, nil
+}
+
+// queryOptions construit les options du logo à partir des paramètres de la requête
+// (les mêmes noms que ceux de la ligne de commande)
+func queryOptions(q url.Values) (o marianne.Options, err error) {
+	o = marianne.DefaultOptions()
+	o.Creator = "marianne " + version
+	if v, ok := q["institution"]; ok && v[0] != "" {
+		o.Institution = v[0]
+	}
+	o.Direction = q.Get("direction")
+	if v, ok := q["eol"]; ok {
+		o.EOL = v[0]
+	}
+	if o.Wrap.MaxLines, err = queryInt(q, "lignes", 0, 0, 10); err != nil {
+		return
+	}
+	if v := q.Get("largeur-texte"); v != "" {
+		if o.Wrap.MaxWidth, err = strconv.ParseFloat(v, 64); err != nil || o.Wrap.MaxWidth < 0 {
+			return o, &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("valeur invalide pour largeur-texte : %q", v)}
+		}
+	}
+	if len(o.Institution) > maxTexte || len(o.Direction) > maxTexte {
+		return o, &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("texte trop long (au plus %d caractères)", maxTexte)}
+	}
+	if o.Signature, err = queryBool(q, "pour-signature"); err != nil {
+		return
+	}
+	if o.NoMargins, err = queryBool(q, "sans-marges"); err != nil {
+		return
+	}
+	if o.Colors16, err = queryBool(q, "seize-couleurs"); err != nil {
+		return
+	}
+	if o.Colors, err = queryInt(q, "couleurs", 0, 0, 256); err != nil {
+		return
+	}
+	if o.Dither, err = queryBool(q, "tramage"); err != nil {
+		return
+	}
+	if o.PNGOptimization, err = queryInt(q, "optimisation-png", 0, marianne.PNGOptimizationNone, marianne.PNGOptimizationMax); err != nil {
+		return
+	}
+	if o.Transparent, err = queryBool(q, "transparent"); err != nil {
+		return
+	}
+	if o.Hinting, err = queryBool(q, "alignement-pixels"); err != nil {
+		return
+	}
+	if o.Filter, err = marianne.ParseFilter(q.Get("filtre")); err != nil {
+		return
+	}
+	if o.Variant, err = marianne.ParseVariant(q.Get("variante")); err != nil {
+		return
+	}
+	if v := q.Get("fond"); v != "" {
+		if o.Background, err = marianne.ParseColor(v); err != nil {
+			return
+		}
+	}
+	if o.ColorMode, err = marianne.ParseColorMode(q.Get("mode")); err != nil {
+		return
+	}
+	if v := q.Get("encre"); v != "" {
+		if o.Ink, err = marianne.ParseColor(v); err != nil {
+			return
+		}
+	}
+	if o.CMYK, err = queryBool(q, "impression"); err != nil {
+		return
+	}
+	if o.Spot, err = queryBool(q, "tons-directs"); err != nil {
+		return
+	}
+	o.CMYK = o.CMYK || o.Spot
+	if o.PDFStandard, err = marianne.ParsePDFStandard(q.Get("norme-pdf")); err != nil {
+		return
+	}
+	if o.JPEGQuality, err = queryInt(q, "qualite-jpg", o.JPEGQuality, 1, 100); err != nil {
+		return
+	}
+	if o.WebPQuality, err = queryInt(q, "qualite-webp", o.WebPQuality, 0, 100); err != nil {
+		return
+	}
+	def := 700
+	if o.Signature {
+		def = 100
+	}
+	h, err := queryInt(q, "hauteur", def, 1, maxHauteur)
+	if err != nil {
+		return
+	}
+	o.Height = uint(h)
+	// la largeur (à la place de la hauteur) et la résolution enregistrée dans les PNG et JPG
+	l, err := queryInt(q, "largeur", 0, 0, maxHauteur)
+	if err != nil {
+		return
+	}
+	o.Width = uint(l)
+	d, err := queryInt(q, "dpi", 0, 0, 2400)
+	o.DPI = float64(d)
+	return
+}
+
+// ServeHTTP répond aux requêtes /logo.<format>?institution=...
+func (s *logoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+	// le format est donné par l'extension ou par le paramètre "format"
+	format := strings.TrimPrefix(path.Ext(r.URL.Path), ".")
+	if format == "" {
+		format = r.URL.Query().Get("format")
+	}
+	if format == "" {
+		format = "svg"
+	}
+	if !marianne.IsFormat(format) {
+		s.error(w, r, &marianne.Error{Kind: marianne.ParameterError, Op: fmt.Sprintf("format inconnu %q", format)})
+		return
+	}
+	opts, err := queryOptions(r.URL.Query())
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	// le logo est-il déjà dans le cache ? (jpg et jpeg, ou PNG et png, sont le même logo)
+	format = marianne.NormalizeFormat(format)
+	key := fmt.Sprintf("%s|%#v", format, opts)
+	data, ok := s.cache.get(key)
+	if !ok {
+		var buf bytes.Buffer
+		if err = marianne.Encode(&buf, format, opts); err != nil {
+			s.error(w, r, err)
+			return
+		}
+		data = buf.Bytes()
+		s.cache.put(key, data)
+	}
+	log(fmt.Sprintf("%s %s (%d octets)\n", r.Method, r.URL.RequestURI(), len(data)))
+
+	w.Header().Set("Content-Type", marianne.MimeType(format))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if q := r.URL.Query(); q.Get("telecharger") != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"logo.%s\"", format))
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// répond avec l'erreur err (400 pour un paramètre invalide, 500 sinon)
+func (s *logoServer) error(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	if marianne.KindOf(err) == marianne.ParameterError {
+		status = http.StatusBadRequest
+	}
+	log(fmt.Sprintf("%s %s : ERREUR %v\n", r.Method, r.URL.RequestURI(), err))
+	http.Error(w, "ERREUR : "+err.Error(), status)
+}
+
+// le formulaire HTML de la page d'accueil
+var formulaire = template.Must(template.New("formulaire").Parse(`<!DOCTYPE html>
+<html lang="fr">
+<head>
+<meta charset="utf-8">
+<title>Marianne (version : {{.}})</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; }
+label { display: block; margin: .5em 0; }
+input[type=text] { width: 100%; }
+</style>
+</head>
+<body>
+<h1>Générer le logo de l'institution</h1>
+<form action="/logo" method="get">
+<label>Institution (le passage à la ligne se fait avec \)
+<input type="text" name="institution" value="RÉPUBLIQUE\FRANÇAISE"></label>
+<label>Direction
+<input type="text" name="direction"></label>
+<label>Nombre de lignes (passage à la ligne automatique)
+<input type="number" name="lignes" min="0" max="10" placeholder="0"></label>
+<label>Format
+<select name="format">
+<option>svg</option><option>pdf</option><option>eps</option>
+<option>png</option><option>gif</option><option>jpg</option><option>webp</option>
+</select></label>
+<label>Hauteur (pour PNG, GIF, JPG et WebP)
+<input type="number" name="hauteur" min="1" max="5000" placeholder="700"></label>
+<label>Largeur (à la place de la hauteur)
+<input type="number" name="largeur" min="0" max="5000" placeholder="0"></label>
+<label>Résolution en dpi (PNG et JPG)
+<input type="number" name="dpi" min="0" max="2400" placeholder="0"></label>
+<label>Qualité des WebP (0 sans perte, sinon 1 à 100)
+<input type="number" name="qualite-webp" min="0" max="100" placeholder="0"></label>
+<label><input type="checkbox" name="sans-marges"> Sans zone de protection</label>
+<label><input type="checkbox" name="pour-signature"> Pour une signature mail</label>
+<label>Nombre de couleurs (PNG, GIF et WebP sans perte, palette adaptée à l'image)
+<input type="number" name="couleurs" min="0" max="256" placeholder="8"></label>
+<label><input type="checkbox" name="tramage"> Tramage des couleurs</label>
+<label>Optimisation des PNG (0 aucune, 1 compression maximale, 2 tous les essais)
+<input type="number" name="optimisation-png" min="0" max="2" placeholder="0"></label>
+<label><input type="checkbox" name="transparent"> Fond transparent</label>
+<label><input type="checkbox" name="alignement-pixels"> Trait et lignes de base alignés sur les pixels</label>
+<label>Variante
+<select name="variante">
+<option value="positif">positif (en couleurs sur fond blanc)</option>
+<option value="negatif">négatif (en blanc sur fond de couleur)</option>
+</select></label>
+<label>Couleur du fond (variante négative)
+<input type="color" name="fond" value="#000091"></label>
+<label>Mode de couleurs
+<select name="mode">
+<option value="couleur">couleur</option>
+<option value="gris">niveaux de gris</option>
+<option value="mono">une seule encre</option>
+</select></label>
+<label>Encre (mode une seule encre)
+<input type="color" name="encre" value="#000000"></label>
+<label><input type="checkbox" name="impression"> Couleurs d'impression CMJN (PDF et EPS)</label>
+<label><input type="checkbox" name="tons-directs"> Tons directs Pantone (PDF et EPS)</label>
+<label>Norme PDF
+<select name="norme-pdf">
+<option value="">aucune</option>
+<option value="pdfa-2b">PDF/A-2b (archivage)</option>
+</select></label>
+<label><input type="checkbox" name="telecharger"> Télécharger le fichier</label>
+<button type="submit">Générer</button>
+</form>
+</body>
+</html>
+`))
+
+// la page d'accueil avec le formulaire
+func accueil(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	formulaire.Execute(w, version)
+}
+
+// AideServe affiche l'aide du mode serveur
+func AideServe(fs *flag.FlagSet) func() {
+	return func() {
+		var out = fs.Output()
+		fmt.Fprintf(out, "marianne serve (version: %s)\n\n", version)
+		fmt.Fprintf(out, "Lance un serveur HTTP qui génère les logos à la demande :\n")
+		fmt.Fprintf(out, "  GET /logo.svg?institution=...&direction=...&hauteur=300\n")
+		fmt.Fprintf(out, "(les paramètres ont les mêmes noms que ceux de la ligne de commande).\n")
+		fmt.Fprintf(out, "Paramètres disponibles:\n\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(out, "\n")
+	}
+}
+
+// serve lance le serveur HTTP (la commande `marianne serve`)
+func serve(args []string) {
+	var (
+		adresse string
+		taille  int
+	)
+	fs := flag.NewFlagSet("marianne serve", flag.ContinueOnError)
+	fs.StringVarP(&adresse, "adresse", "a", "localhost:8080", "L'adresse (et le port) d'écoute du serveur.")
+	fs.IntVar(&taille, "cache", 64, "La mémoire (en Mo) des derniers logos gardés (0 pour désactiver le cache).")
+	fs.BoolVarP(&silence, "silence", "q", false, "N'imprime rien.")
+	fs.BoolVarP(&aide, "aide", "h", false, "Imprime ce message d'aide.")
+	fs.SortFlags = false
+	fs.SetOutput(FrenchTranslator{os.Stderr})
+	fs.Usage = AideServe(fs)
+
+	err = fs.Parse(args)
+	if aide || err != nil {
+		fs.Usage()
+		if err != nil {
+			fmt.Fprintln(fs.Output(), "ERREUR : ", err)
+			os.Exit(exitParametre)
+		}
+		os.Exit(0)
+	}
+	if silence {
+		log = func(msg ...interface{}) {}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", accueil)
+	ls := &logoServer{cache: newCache(taille << 20)}
+	mux.Handle("/logo", ls)
+	for _, f := range marianne.Formats {
+		mux.Handle("/logo."+f, ls)
+	}
+	mux.Handle("/logo.jpeg", ls)
+
+	log(fmt.Sprintf("Serveur à l'écoute sur http://%s/\n", adresse))
+	srv := &http.Server{
+		Addr:              adresse,
+		Handler:           mux,
+		ReadHeaderTimeout: delaiEntetes,
+		ReadTimeout:       delaiLecture,
+		WriteTimeout:      delaiReponse,
+	}
+	if err = srv.ListenAndServe(); err != nil {
+		fatal(ioError("serveur HTTP", err))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	c := newCache(10)
+	c.put("a", make([]byte, 4))
+	c.put("b", make([]byte, 4))
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("a devrait être dans le cache")
+	}
+	// "b" est le plus ancien : il est supprimé pour faire de la place à "c"
+	c.put("c", make([]byte, 4))
+	if _, ok := c.get("b"); ok {
+		t.Error("b devrait avoir été supprimé")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("a devrait être dans le cache")
+	}
+	if c.size != 8 {
+		t.Errorf("taille %d au lieu de 8", c.size)
+	}
+	// un logo plus gros que le cache n'est pas gardé
+	c.put("d", make([]byte, 11))
+	if _, ok := c.get("d"); ok {
+		t.Error("d ne devrait pas être dans le cache")
+	}
+	// remplacer un logo met à jour la taille
+	c.put("a", make([]byte, 6))
+	if c.size != 10 || c.order.Len() != 2 {
+		t.Errorf("taille %d avec %d logos au lieu de 10 avec 2", c.size, c.order.Len())
+	}
+	// un cache vide ne garde rien
+	c = newCache(0)
+	c.put("a", nil)
+	if c.order.Len() != 0 {
+		t.Error("le cache désactivé ne devrait rien garder")
+	}
+}
+
+func TestServeCacheFormat(t *testing.T) {
+	ls := &logoServer{cache: newCache(1 << 20)}
+	for _, u := range []string{"/logo.jpg?hauteur=50", "/logo.JPEG?hauteur=50", "/logo?format=jpeg&hauteur=50"} {
+		w := httptest.NewRecorder()
+		ls.ServeHTTP(w, httptest.NewRequest(http.MethodGet, u, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s : statut %d (%s)", u, w.Code, w.Body)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/jpeg" {
+			t.Errorf("%s : type %q", u, ct)
+		}
+	}
+	// les trois noms du format donnent le même logo, gardé une seule fois
+	if n := ls.cache.order.Len(); n != 1 {
+		t.Errorf("%d logos dans le cache au lieu de 1", n)
+	}
+}
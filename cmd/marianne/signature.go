@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+// avecSignature indique si la signature mail doit être générée (c.-à-d. si au moins
+// une des coordonnées de l'agent est donnée)
+func avecSignature() bool {
+	return agent != "" || fonction != "" || telephone != "" || adresse != "" || courriel != ""
+}
+
+// saveSignature enregistre la signature mail HTML et sa version texte avec le logo img
+// (déjà enregistré dans le fichier pngName), intégré ou référencé avec --url-image
+func saveSignature(img image.Image, pngName string, ch champs) error {
+	a := marianne.Agent{Name: agent, Title: fonction, Phone: telephone, Address: adresse, Email: courriel}
+	si := marianne.SignatureImage{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if urlImage != "" {
+		// le chemin du PNG par rapport au dossier (le modèle peut contenir des sous-dossiers)
+		rel, err := filepath.Rel(dossier, pngName)
+		if err != nil {
+			rel = filepath.Base(pngName)
+		}
+		si.Src = strings.TrimSuffix(urlImage, "/") + "/" + filepath.ToSlash(rel)
+	} else {
+		var buf bytes.Buffer
+		if err := marianne.EncodeImage(&buf, img, "png", opts); err != nil {
+			return err
+		}
+		si.Src = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	ch["ext"] = "html"
+	if err := saveFile(fileName(modeleVect, ch), func(w io.Writer) error {
+		return marianne.EncodeSignatureHTML(w, a, si, opts)
+	}); err != nil {
+		return err
+	}
+	ch["ext"] = "txt"
+	if err := saveFile(fileName(modeleVect, ch), func(w io.Writer) error {
+		return marianne.EncodeSignatureText(w, a, opts)
+	}); err != nil {
+		return err
+	}
+	log(" Signature faite.")
+	return nil
+}
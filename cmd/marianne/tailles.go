@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/kpym/marianne"   // la génération du logo
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// la taille d'une image matricielle : une hauteur ou une largeur en pixels
+type dimension struct {
+	pixels      uint
+	largeur     bool   // pixels est la largeur (sinon c'est la hauteur)
+	description string // pour les messages, par exemple "de hauteur 700"
+}
+
+// les tailles des images matricielles (voir lireDimensions)
+var dimensions []dimension
+
+// image dessine le canevas c à la taille d (avec le suréchantillonnage et le filtre des options)
+func (d dimension) image(c *canvas.Canvas) image.Image {
+	if d.largeur {
+		return marianne.CanvasToImage(c, d.pixels, 0, opts)
+	}
+	return marianne.CanvasToImage(c, 0, d.pixels, opts)
+}
+
+// taille retourne la largeur et la hauteur en pixels de l'image du canevas c à la taille d
+func (d dimension) taille(c *canvas.Canvas) (uint, uint) {
+	if d.largeur {
+		return d.pixels, marianne.HeightForWidth(c, d.pixels)
+	}
+	return marianne.WidthForHeight(c, d.pixels), d.pixels
+}
+
+// fois retourne la taille d multipliée par k (pour les densités d'écran @2x, @3x...)
+func (d dimension) fois(k uint) dimension {
+	d.pixels *= k
+	return d
+}
+
+// lireDimensions retourne les tailles des images à partir de --hauteur, --largeur
+// et --taille (convertie en pixels avec --dpi, 300 par défaut)
+func lireDimensions() ([]dimension, error) {
+	var ds []dimension
+	for _, h := range hauteurs {
+		if h == 0 {
+			return nil, &marianne.Error{Kind: marianne.ParameterError, Op: "la hauteur des images doit être strictement positive"}
+		}
+		ds = append(ds, dimension{h, false, fmt.Sprint("de hauteur ", h)})
+	}
+	for _, l := range largeurs {
+		if l == 0 {
+			return nil, &marianne.Error{Kind: marianne.ParameterError, Op: "la largeur des images doit être strictement positive"}
+		}
+		ds = append(ds, dimension{l, true, fmt.Sprint("de largeur ", l)})
+	}
+	if dpi < 0 {
+		return nil, &marianne.Error{Kind: marianne.ParameterError, Op: "la résolution --dpi doit être positive"}
+	}
+	if len(tailles) > 0 && dpi == 0 {
+		dpi = 300
+	}
+	for _, t := range tailles {
+		mm, err := marianne.ParseLength(t)
+		if err != nil {
+			return nil, err
+		}
+		px := marianne.PixelsForLength(mm, dpi)
+		ds = append(ds, dimension{px, true, fmt.Sprintf("de %s à %g dpi (largeur %d)", t, dpi, px)})
+	}
+	return ds, nil
+}
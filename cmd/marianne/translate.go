@@ -16,10 +16,13 @@ var traductions = strings.NewReplacer(
 	" string ", "        ",
 	" uints ", "       ",
 	" int ", "     ",
+	" uint ", "      ",
 	"bad flag syntax:", "mauvaise syntaxe du paramètre :",
 	"unknown flag:", "paramètre inconnu :",
 	"unknown shorthand flag:", "paramètre court inconnu :",
 	"flag needs an argument:", "paramètre sans argument :",
+	"Flag --", "Le paramètre --",
+	" has been deprecated, ", " est obsolète, ",
 	" in ", " dans ",
 )
 
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag" // pour les paramètres en ligne de commande
+
+	"github.com/kpym/marianne" // la génération du logo
+)
+
+var (
+	// la commande `marianne verifier` : les logos sont vérifiés au lieu d'être générés
+	verification bool
+	// avec --strict les avertissements et les erreurs donnent un code de sortie non nul
+	strict bool
+)
+
+// initVerification prépare la commande `marianne verifier` (avec les mêmes paramètres
+// que la génération des logos, plus --strict)
+func initVerification() {
+	verification = true
+	flag.BoolVar(&strict, "strict", false, "Termine avec le code 6 si le logo n'est pas conforme à la charte (erreurs ou avertissements).")
+}
+
+// verifier affiche les écarts à la charte du logo décrit par les paramètres
+// (les formats formatstr et les hauteurs) au lieu de le générer
+func verifier(formatstr string) error {
+	out := io.Writer(os.Stdout)
+	if silence {
+		out = ioutil.Discard
+	}
+	// la version avec marges, la plus petite à hauteur égale, est vérifiée si elle est demandée
+	o := opts
+	o.NoMargins = !avecMarges
+	var formats []string
+	for _, f := range marianne.Formats {
+		if strings.Contains(formatstr, f) || (f == "jpg" && strings.Contains(formatstr, "jpeg")) {
+			formats = append(formats, f)
+		}
+	}
+	// les hauteurs des images (celles des largeurs suivent les proportions du logo)
+	var hs []uint
+	if len(largeurs) > 0 || len(tailles) > 0 {
+		c, err := marianne.Render(o)
+		if err != nil {
+			return err
+		}
+		for _, d := range dimensions {
+			_, h := d.taille(c)
+			hs = append(hs, h)
+		}
+	} else {
+		hs = hauteurs
+	}
+	issues, err := marianne.Check(o, formats, hs)
+	if err != nil {
+		return err
+	}
+	var erreurs int
+	for _, i := range issues {
+		fmt.Fprintf(out, "%s\n", i)
+		if i.Severity == marianne.Violation {
+			erreurs++
+		}
+	}
+	if len(issues) == 0 {
+		fmt.Fprintf(out, "Le logo est conforme à la charte.\n")
+		return nil
+	}
+	fmt.Fprintf(out, "%d erreur(s), %d avertissement(s).\n", erreurs, len(issues)-erreurs)
+	if strict {
+		return &marianne.Error{Kind: marianne.CharterError, Op: fmt.Sprintf("le logo n'est pas conforme à la charte (%d erreur(s), %d avertissement(s))", erreurs, len(issues)-erreurs)}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package marianne
+
+import (
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// Brand est un bloc-marque d'un logo en co-marquage
+type Brand struct {
+	// Le nom du ministère, ambassade...
+	Institution string
+	// Intitulé de direction, service ou délégation interministérielles (facultatif).
+	Direction string
+}
+
+// Layout est la disposition des logos en co-marquage
+type Layout string
+
+// les dispositions
+const (
+	// les logos côte à côte, alignés en haut
+	LayoutHorizontal Layout = "horizontal"
+	// les logos les uns sous les autres, alignés à gauche
+	LayoutVertical Layout = "vertical"
+)
+
+// ParseLayout retourne la disposition correspondant au nom (vide pour la disposition horizontale)
+func ParseLayout(name string) (Layout, error) {
+	switch l := Layout(strings.ToLower(strings.TrimSpace(name))); l {
+	case "", LayoutHorizontal:
+		return LayoutHorizontal, nil
+	case LayoutVertical:
+		return l, nil
+	}
+	return "", parameterError("disposition inconnue %q (horizontal ou vertical)", name)
+}
+
+// RenderCoBrand dessine le logo en co-marquage : les blocs-marques brands (avec la même
+// taille de Marianne) puis les logos des partenaires, côte à côte ou les uns sous les
+// autres selon layout, séparés par leurs zones de protection. Les logos des partenaires
+// ont la hauteur du plus haut des blocs-marques et leurs couleurs suivent la variante
+// et le mode de opts. Le fond et les marges suivent opts comme pour Render.
+func RenderCoBrand(opts Options, brands []Brand, partners []*Partner, layout Layout) (*canvas.Canvas, error) {
+	if len(brands) == 0 {
+		return nil, parameterError("le co-marquage demande au moins un bloc-marque")
+	}
+	// les blocs-marques seuls (sans marges ni fond)
+	var logos []*canvas.Canvas
+	var height float64
+	for _, b := range brands {
+		lo := opts
+		lo.Institution, lo.Direction = b.Institution, b.Direction
+		lo.NoMargins, lo.Transparent = true, true
+		logo, err := Render(lo)
+		if err != nil {
+			return nil, err
+		}
+		logos = append(logos, logo)
+		height = math.Max(height, logo.H)
+	}
+	// les logos des partenaires, à la hauteur des blocs-marques
+	col := opts.partnerColor()
+	for _, p := range partners {
+		s := height / p.H
+		logo := canvas.New(p.W*s, height)
+		ctx := canvas.NewContext(logo)
+		ctx.SetView(canvas.Identity.Scale(s, s))
+		p.draw(ctx, col)
+		logos = append(logos, logo)
+	}
+
+	// l'espace entre deux logos : leurs zones de protection (x) ne se chevauchent pas
+	gap := 2 * x
+	c := canvas.New(1, 1) // la taille sera ajustée après avec Fit()
+	ctx := canvas.NewContext(c)
+	var pos float64
+	for _, logo := range logos {
+		if layout == LayoutVertical {
+			ctx.SetView(canvas.Identity.Translate(0, pos-logo.H))
+			pos -= logo.H + gap
+		} else {
+			ctx.SetView(canvas.Identity.Translate(pos, -logo.H))
+			pos += logo.W + gap
+		}
+		logo.Render(ctx)
+	}
+	ctx.ResetView()
+
+	if opts.NoMargins {
+		c.Fit(0.0)
+	} else {
+		c.Fit(x)
+	}
+	if opts.Transparent {
+		return c, nil
+	}
+	return onBackground(c, opts.BackgroundColor()), nil
+}
+
+// partnerColor retourne la transformation des couleurs des logos partenaires : en blanc
+// pour la variante négative, en niveaux de gris ou avec l'encre pour les modes gris et mono
+func (opts Options) partnerColor() func(color.RGBA) color.RGBA {
+	with := func(c color.RGBA, a uint8) color.RGBA {
+		// les couleurs sont prémultipliées par l'opacité
+		f := float64(a) / 255
+		return color.RGBA{uint8(float64(c.R)*f + 0.5), uint8(float64(c.G)*f + 0.5), uint8(float64(c.B)*f + 0.5), a}
+	}
+	if opts.Variant == VariantNegative {
+		return func(c color.RGBA) color.RGBA { return with(canvas.White, c.A) }
+	}
+	switch opts.ColorMode {
+	case ColorModeGray:
+		return func(c color.RGBA) color.RGBA {
+			if c.A == 0 {
+				return c
+			}
+			// la luminance (des couleurs non prémultipliées)
+			f := 255 / float64(c.A)
+			y := uint8(math.Min(255, (0.299*float64(c.R)+0.587*float64(c.G)+0.114*float64(c.B))*f+0.5))
+			return with(color.RGBA{y, y, y, 0xff}, c.A)
+		}
+	case ColorModeMono:
+		ink := opts.inkColor()
+		return func(c color.RGBA) color.RGBA { return with(ink, c.A) }
+	}
+	return func(c color.RGBA) color.RGBA { return c }
+}
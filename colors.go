@@ -0,0 +1,230 @@
+package marianne
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// Variant est la variante du logo
+type Variant string
+
+// les variantes du logo
+const (
+	// la version standard (en couleurs, sur fond blanc)
+	VariantPositive Variant = "positif"
+	// la version pour fonds sombres ou colorés (en blanc, sur fond de couleur)
+	VariantNegative Variant = "negatif"
+)
+
+// ColorMode est le mode de couleurs du logo
+type ColorMode string
+
+// les modes de couleurs
+const (
+	// les couleurs de la charte (bleu, rouge...)
+	ColorModeColor ColorMode = "couleur"
+	// en niveaux de gris (impression en noir et blanc)
+	ColorModeGray ColorMode = "gris"
+	// une seule encre, noire par défaut (fax, tampons, marquage...)
+	ColorModeMono ColorMode = "mono"
+)
+
+// les niveaux de gris des 3 parties de la Marianne en mode ColorModeGray : bleu, gris, rouge
+var grayLogoColor = [3]color.RGBA{
+	{0x33, 0x33, 0x33, 0xff}, // bleu -> 80% de noir
+	{0xb3, 0xb3, 0xb3, 0xff}, // gris -> 30% de noir
+	{0x80, 0x80, 0x80, 0xff}, // rouge -> 50% de noir
+}
+
+// BleuFrance est la couleur de fond par défaut de la variante négative
+var BleuFrance = color.RGBA{0x00, 0x00, 0x91, 0xff}
+
+// ParseVariant retourne la variante correspondant au nom (vide pour la version standard)
+func ParseVariant(name string) (Variant, error) {
+	switch v := Variant(strings.ToLower(strings.TrimSpace(name))); v {
+	case "", VariantPositive:
+		return VariantPositive, nil
+	case VariantNegative, "négatif":
+		return VariantNegative, nil
+	}
+	return "", parameterError("variante inconnue %q (positif ou negatif)", name)
+}
+
+// ParseColorMode retourne le mode de couleurs correspondant au nom (vide pour les couleurs de la charte)
+func ParseColorMode(name string) (ColorMode, error) {
+	switch m := ColorMode(strings.ToLower(strings.TrimSpace(name))); m {
+	case "", ColorModeColor, "couleurs":
+		return ColorModeColor, nil
+	case ColorModeGray, ColorModeMono:
+		return m, nil
+	}
+	return "", parameterError("mode de couleurs inconnu %q (couleur, gris ou mono)", name)
+}
+
+// ParseColor lit une couleur sous la forme "#rrggbb" ou "#rgb" (le # est facultatif)
+func ParseColor(s string) (color.RGBA, error) {
+	h := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if len(h) != 6 || err != nil {
+		return color.RGBA{}, parameterError("couleur invalide %q (par exemple #000091)", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+// FormatColor écrit la couleur c sous la forme "#rrggbb"
+func FormatColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// les couleurs utilisées pour dessiner le logo
+type logoColors struct {
+	// les 3 parties de la Marianne : bleu, gris, rouge
+	marianne [3]color.RGBA
+	// les textes, la devise et le trait séparateur
+	text color.RGBA
+	// le fond
+	background color.RGBA
+}
+
+// colors retourne les couleurs du logo en fonction de la variante et du mode de couleurs
+func (opts Options) colors() logoColors {
+	if opts.Variant == VariantNegative {
+		bg := opts.Background
+		if bg.A == 0 {
+			bg = BleuFrance
+		}
+		// en négatif toute la Marianne est en blanc (en réserve)
+		return logoColors{
+			marianne:   [3]color.RGBA{canvas.White, canvas.White, canvas.White},
+			text:       canvas.White,
+			background: bg,
+		}
+	}
+	switch opts.ColorMode {
+	case ColorModeGray:
+		return logoColors{
+			marianne:   grayLogoColor,
+			text:       canvas.Black,
+			background: canvas.White,
+		}
+	case ColorModeMono:
+		ink := opts.inkColor()
+		return logoColors{
+			marianne:   [3]color.RGBA{ink, ink, ink},
+			text:       ink,
+			background: canvas.White,
+		}
+	}
+	return logoColors{
+		marianne:   [3]color.RGBA{logoColor[0], logoColor[1], logoColor[2]},
+		text:       canvas.Black,
+		background: canvas.White,
+	}
+}
+
+// la couleur de l'encre en mode ColorModeMono (noir si elle n'est pas précisée)
+func (opts Options) inkColor() color.RGBA {
+	if opts.Ink.A == 0 {
+		return canvas.Black
+	}
+	return opts.Ink
+}
+
+// BackgroundColor retourne la couleur du fond du logo (blanc pour la version standard)
+func (opts Options) BackgroundColor() color.RGBA {
+	return opts.colors().background
+}
+
+// Palette retourne la palette de 8 ou 16 couleurs utilisée pour les PNG et GIF
+// (les gris du logo et des niveaux de gris, ou des nuances de l'encre, pour les modes gris et mono),
+// complétée en couleurs par opts.ExtraColors
+func (opts Options) Palette() color.Palette {
+	n := 8
+	if opts.Colors16 {
+		n = 16
+	}
+	if opts.Variant == VariantNegative {
+		// du fond jusqu'au blanc
+		c := opts.colors()
+		return gradient(c.background, c.text, n)
+	}
+	switch opts.ColorMode {
+	case ColorModeGray:
+		// les gris exacts du logo, complétés par des niveaux réguliers (plus serrés si
+		// certains tombent sur les gris du logo)
+		c := opts.colors()
+		fixed := color.Palette{c.background, c.text, c.marianne[0], c.marianne[1], c.marianne[2]}
+		p := fixed
+		for k := n - len(fixed) + 2; len(p) < n; k++ {
+			p = append(color.Palette{}, fixed...)
+			for _, g := range gradient(canvas.White, canvas.Black, k) {
+				if len(p) < n && !hasColor(p, g) {
+					p = append(p, g)
+				}
+			}
+		}
+		return p
+	case ColorModeMono:
+		return gradient(canvas.White, opts.inkColor(), n)
+	}
+	p := MariannePalette8
+	if opts.Colors16 {
+		p = MariannePalette16
+	}
+	if len(opts.ExtraColors) == 0 {
+		return p
+	}
+	// les couleurs ajoutées (absentes de la palette), jusqu'à 256 couleurs ;
+	// les couleurs transparentes sont celles vues sur le fond
+	p = append(color.Palette{}, p...)
+	bg := opts.BackgroundColor()
+	for _, c := range opts.ExtraColors {
+		if c.A != 0xff {
+			c = over(c, bg)
+		}
+		if len(p) < 256 && !hasColor(p, c) {
+			p = append(p, c)
+		}
+	}
+	return p
+}
+
+// hasColor indique si la palette p contient exactement la couleur c (quel que soit son type)
+func hasColor(p color.Palette, c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	for _, q := range p {
+		if qr, qg, qb, qa := q.RGBA(); qr == r && qg == g && qb == b && qa == a {
+			return true
+		}
+	}
+	return false
+}
+
+// gradient retourne une palette de n couleurs allant de c1 à c2
+func gradient(c1, c2 color.RGBA, n int) color.Palette {
+	p := make(color.Palette, n)
+	mix := func(a, b uint8, t float64) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+	}
+	for i := 0; i < n; i++ {
+		t := float64(i) / float64(n-1)
+		p[i] = color.RGBA{mix(c1.R, c2.R, t), mix(c1.G, c2.G, t), mix(c1.B, c2.B, t), 0xff}
+	}
+	return p
+}
+
+// over retourne la couleur c (prémultipliée par son opacité) vue sur le fond opaque bg
+func over(c, bg color.RGBA) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + float64(b)*float64(255-c.A)/255 + 0.5)
+	}
+	return color.RGBA{mix(c.R, bg.R), mix(c.G, bg.G), mix(c.B, bg.B), 0xff}
+}
@@ -0,0 +1,63 @@
+package marianne
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestGrayPalette(t *testing.T) {
+	for _, c16 := range []bool{false, true} {
+		opts := Options{ColorMode: ColorModeGray, Colors16: c16}
+		p := opts.Palette()
+		n := 8
+		if c16 {
+			n = 16
+		}
+		if len(p) != n {
+			t.Errorf("%d couleurs au lieu de %d : %v", len(p), n, p)
+		}
+		for _, g := range append(grayLogoColor[:], color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0, 0, 0, 0xff}) {
+			if !hasColor(p, g) {
+				t.Errorf("le gris %v du logo manque dans la palette %v", g, p)
+			}
+		}
+		for i := range p {
+			for j := range p[:i] {
+				if p[i] == p[j] {
+					t.Errorf("la couleur %v est en double dans %v", p[i], p)
+				}
+			}
+		}
+
+		// les gris du logo sont gardés dans l'image indexée
+		c, err := Render(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := IndexImage(CanvasToImage(c, 0, 200, opts), opts).(*image.Paletted)
+		used := map[color.Color]bool{}
+		for _, i := range img.Pix {
+			used[img.Palette[i]] = true
+		}
+		for _, g := range grayLogoColor {
+			if !used[g] {
+				t.Errorf("le gris %v du logo n'est pas dans l'image", g)
+			}
+		}
+	}
+}
+
+func TestPaletteExtraColors(t *testing.T) {
+	base := Options{}.Palette()
+	// les couleurs déjà dans la palette ne sont pas ajoutées une seconde fois
+	var extra []color.RGBA
+	for _, c := range base[:3] {
+		r, g, b, _ := c.RGBA()
+		extra = append(extra, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff})
+	}
+	opts := Options{ExtraColors: append(extra, color.RGBA{0x12, 0x34, 0x56, 0xff})}
+	if p := opts.Palette(); len(p) != len(base)+1 {
+		t.Errorf("%d couleurs au lieu de %d : %v", len(p), len(base)+1, p)
+	}
+}
@@ -0,0 +1,92 @@
+package marianne
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// les unités de longueur acceptées par ParseLength (en mm)
+var lengthUnits = []struct {
+	name string
+	mm   float64
+}{
+	{"mm", 1},
+	{"cm", 10},
+	{"in", 25.4},
+	{"pt", 25.4 / 72},
+}
+
+// ParseLength retourne en millimètres la longueur s, un nombre suivi de son unité :
+// mm, cm, in (pouces) ou pt (points), par exemple "30mm" ou "1.5in"
+func ParseLength(s string) (float64, error) {
+	t := strings.ToLower(strings.TrimSpace(s))
+	for _, u := range lengthUnits {
+		if strings.HasSuffix(t, u.name) {
+			v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(t, u.name)), 64)
+			if err != nil || v <= 0 {
+				break
+			}
+			return v * u.mm, nil
+		}
+	}
+	return 0, parameterError("longueur invalide %q (un nombre positif suivi de mm, cm, in ou pt, par exemple 30mm)", s)
+}
+
+// PixelsForLength retourne le nombre de pixels d'une longueur de mm millimètres imprimée
+// à la résolution dpi (en points par pouce)
+func PixelsForLength(mm, dpi float64) uint {
+	if px := math.Round(mm / 25.4 * dpi); px >= 1 {
+		return uint(px)
+	}
+	return 1
+}
+
+// withDensity appelle enc sur w, puis si dpi est positif ajoute la résolution au
+// fichier produit avec insert (pngDensity ou jpegDensity)
+func withDensity(w io.Writer, dpi float64, insert func(data []byte, dpi float64) []byte, enc func(w io.Writer) error) error {
+	if dpi <= 0 {
+		return enc(w)
+	}
+	var buf bytes.Buffer
+	if err := enc(&buf); err != nil {
+		return err
+	}
+	_, err := w.Write(insert(buf.Bytes(), dpi))
+	return err
+}
+
+// pngDensity ajoute le bloc pHYs (la résolution en pixels par mètre) juste après
+// le bloc IHDR du PNG data
+func pngDensity(data []byte, dpi float64) []byte {
+	ppm := uint32(math.Round(dpi / 0.0254))
+	phys := make([]byte, 9)
+	binary.BigEndian.PutUint32(phys[0:], ppm)
+	binary.BigEndian.PutUint32(phys[4:], ppm)
+	phys[8] = 1 // l'unité est le mètre
+	var chunk bytes.Buffer
+	writeChunk(&chunk, "pHYs", phys)
+	return insertAfterIHDR(data, chunk.Bytes())
+}
+
+// jpegDensity ajoute l'en-tête JFIF (APP0) avec la résolution en points par pouce
+// juste après le marqueur SOI du JPG data (image/jpeg n'écrit pas d'en-tête JFIF)
+func jpegDensity(data []byte, dpi float64) []byte {
+	if len(data) < 2 {
+		return data
+	}
+	d := math.Round(dpi)
+	if d > math.MaxUint16 {
+		d = math.MaxUint16
+	}
+	app0 := []byte{0xff, 0xe0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 1, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(app0[12:], uint16(d))
+	binary.BigEndian.PutUint16(app0[14:], uint16(d))
+	out := make([]byte, 0, len(data)+len(app0))
+	out = append(out, data[:2]...)
+	out = append(out, app0...)
+	return append(out, data[2:]...)
+}
@@ -0,0 +1,178 @@
+package marianne
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"regexp" // pour les ajustements dans le svg
+	"strings"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+	"github.com/tdewolff/canvas/eps"
+	"github.com/tdewolff/canvas/pdf"
+	"github.com/tdewolff/canvas/svg"
+	"github.com/tdewolff/minify/v2"
+	minsvg "github.com/tdewolff/minify/v2/svg"
+)
+
+// Formats contient la liste des formats supportés
+var Formats = []string{"svg", "pdf", "eps", "png", "gif", "jpg", "webp"}
+
+// IsFormat indique si le format fait partie des formats supportés
+func IsFormat(format string) bool {
+	format = NormalizeFormat(format)
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRaster indique si le format est matriciel (PNG, GIF, JPG, WebP)
+func IsRaster(format string) bool {
+	switch NormalizeFormat(format) {
+	case "png", "gif", "jpg", "webp":
+		return true
+	}
+	return false
+}
+
+// MimeType retourne le type MIME du format (vide si le format est inconnu)
+func MimeType(format string) string {
+	switch NormalizeFormat(format) {
+	case "svg":
+		return "image/svg+xml"
+	case "pdf":
+		return "application/pdf"
+	case "eps":
+		return "application/postscript"
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	case "jpg":
+		return "image/jpeg"
+	case "webp":
+		return "image/webp"
+	}
+	return ""
+}
+
+// NormalizeFormat retourne le nom normalisé du format : en minuscules, sans point et "jpeg" -> "jpg"
+func NormalizeFormat(format string) string {
+	format = strings.ToLower(strings.TrimPrefix(format, "."))
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
+
+// Encode dessine le logo puis l'écrit dans w au format donné
+func Encode(w io.Writer, format string, opts Options) error {
+	c, err := Render(opts)
+	if err != nil {
+		return err
+	}
+	return EncodeCanvas(w, c, format, opts)
+}
+
+// EncodeCanvas écrit le logo c (obtenu avec Render) dans w au format donné
+func EncodeCanvas(w io.Writer, c *canvas.Canvas, format string, opts Options) error {
+	opts = opts.normalize()
+	switch format = NormalizeFormat(format); format {
+	case "svg":
+		return encode(w, "SVG", func(w io.Writer) error { return writeSVG(w, c) })
+	case "pdf":
+		if opts.CMYK || opts.PDFStandard != PDFStandardNone {
+			return encode(w, "PDF", func(w io.Writer) error { return writePDF(w, []Page{{Canvas: c}}, opts) })
+		}
+		return encode(w, "PDF", func(w io.Writer) error { return pdf.Writer(w, c) })
+	case "eps":
+		if opts.CMYK {
+			return encode(w, "EPS", func(w io.Writer) error { return writePrintEPS(w, c, opts) })
+		}
+		return encode(w, "EPS", func(w io.Writer) error { return eps.Writer(w, c) })
+	case "png", "gif", "jpg", "webp":
+		if opts.Width > 0 {
+			return EncodeImage(w, CanvasToImage(c, opts.Width, 0, opts), format, opts)
+		}
+		return EncodeImage(w, CanvasToImage(c, 0, opts.Height, opts), format, opts)
+	}
+	return parameterError("format inconnu : %q", format)
+}
+
+// EncodeImage écrit l'image img (obtenue avec CanvasToRGBAImg) dans w au format
+// donné (PNG, GIF, JPG ou WebP). Pour les PNG, les GIF et les WebP sans perte l'image
+// est réduite à 8 ou 16 couleurs, ou à opts.Colors, si ce n'est pas déjà fait (avec IndexImage), sauf
+// avec un fond transparent où toute la couche alpha est gardée ; les GIF, et les PNG avec
+// opts.Colors, ont alors une couleur transparente en plus des autres. Le JPG n'ayant pas de
+// transparence, l'image est alors mise sur la couleur de fond du logo. Avec opts.DPI la
+// résolution est enregistrée dans les PNG (bloc pHYs) et les JPG (en-tête JFIF). Les PNG
+// sont optimisés sans perte selon opts.PNGOptimization (voir OptimizePNG).
+func EncodeImage(w io.Writer, img image.Image, format string, opts Options) error {
+	opts = opts.normalize()
+	_, indexed := img.(*image.Paletted)
+	switch format = NormalizeFormat(format); format {
+	case "png":
+		if !indexed {
+			if !opts.Transparent {
+				img = IndexImage(img, opts)
+			} else if opts.Colors > 0 {
+				img = transparentIndexImg(img, opts)
+			}
+		}
+		return encode(w, "PNG", func(w io.Writer) error {
+			return withDensity(w, opts.DPI, pngDensity, func(w io.Writer) error { return writePNG(w, img, opts.PNGOptimization) })
+		})
+	case "gif":
+		if !indexed {
+			if opts.Transparent {
+				img = transparentIndexImg(img, opts)
+			} else {
+				img = IndexImage(img, opts)
+			}
+		}
+		return encode(w, "GIF", func(w io.Writer) error { return gif.Encode(w, img, nil) })
+	case "jpg":
+		if opts.Transparent {
+			img = FlattenImg(img, opts.BackgroundColor())
+		}
+		return encode(w, "JPG", func(w io.Writer) error {
+			return withDensity(w, opts.DPI, jpegDensity, func(w io.Writer) error {
+				return jpeg.Encode(w, img, &jpeg.Options{Quality: opts.JPEGQuality})
+			})
+		})
+	case "webp":
+		if !indexed && !opts.Transparent && opts.WebPQuality == 0 {
+			img = IndexImage(img, opts)
+		}
+		return encode(w, "WebP", func(w io.Writer) error { return writeWebP(w, img, opts.WebPQuality) })
+	}
+	return parameterError("format matriciel inconnu : %q", format)
+}
+
+// écrit le SVG compressé (sans 'width', 'height' et sans les parties fractionnaires)
+func writeSVG(w io.Writer, c *canvas.Canvas) error {
+	// au lieu de fichier on utilise un Buffer
+	var memoryFile = new(bytes.Buffer)
+	if err := svg.Writer(memoryFile, c); err != nil {
+		return err
+	}
+	var buf = memoryFile.Bytes()
+	// et on supprime 'width' et 'height'
+	reWidth := regexp.MustCompile(`(?m)(width\s*=\s*\"[^"]*\"\s*)`)
+	buf = reWidth.ReplaceAll(buf, []byte{})
+	reHeight := regexp.MustCompile(`(?m)(height\s*=\s*\"[^"]*\"\s*)`)
+	buf = reHeight.ReplaceAll(buf, []byte{})
+	// suppression de la partie fractale
+	reNumbers := regexp.MustCompile(`(?m)([ MZLHVCSQTA+-]\d*)\.\d+`)
+	buf = reNumbers.ReplaceAll(buf, []byte("$1"))
+	// compression du SVG (réécriture en coordonnées relatives)
+	mediatype := "image/svg+xml"
+	m := minify.New()
+	m.AddFunc(mediatype, minsvg.Minify)
+	return m.Minify(mediatype, w, bytes.NewReader(buf))
+}
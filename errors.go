@@ -0,0 +1,111 @@
+package marianne
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrorKind précise la nature d'une erreur retournée par le package
+type ErrorKind int
+
+// les différentes natures d'erreurs
+const (
+	// paramètre invalide (format inconnu, hauteur nulle...)
+	ParameterError ErrorKind = iota + 1
+	// la police Marianne n'a pas pu être chargée
+	FontError
+	// erreur de lecture ou d'écriture (fichier, disque plein...)
+	IOError
+	// erreur lors de l'encodage d'un des formats
+	EncodingError
+	// le logo n'est pas conforme à la charte (voir Check)
+	CharterError
+)
+
+// String retourne la nature de l'erreur en français
+func (k ErrorKind) String() string {
+	switch k {
+	case ParameterError:
+		return "paramètre invalide"
+	case FontError:
+		return "police"
+	case IOError:
+		return "entrée/sortie"
+	case EncodingError:
+		return "encodage"
+	case CharterError:
+		return "charte"
+	}
+	return "inconnue"
+}
+
+// Error est l'erreur retournée par les fonctions du package
+type Error struct {
+	// la nature de l'erreur
+	Kind ErrorKind
+	// l'opération qui a échoué (par exemple "encodage PNG")
+	Op string
+	// l'erreur d'origine
+	Err error
+}
+
+// Error retourne le message d'erreur
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Op
+	}
+	return e.Op + " : " + e.Err.Error()
+}
+
+// Unwrap retourne l'erreur d'origine
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf retourne la nature de l'erreur err (0 si ce n'est pas une *Error)
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return 0
+}
+
+// crée une erreur de paramètre
+func parameterError(format string, a ...interface{}) error {
+	return &Error{Kind: ParameterError, Op: fmt.Sprintf(format, a...)}
+}
+
+// errWriter garde la première erreur d'écriture afin de pouvoir distinguer
+// les erreurs d'entrée/sortie des erreurs d'encodage
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write écrit p dans ew.w en retenant la première erreur
+func (ew *errWriter) Write(p []byte) (int, error) {
+	n, err := ew.w.Write(p)
+	if err != nil && ew.err == nil {
+		ew.err = err
+	}
+	return n, err
+}
+
+// encode appelle enc sur w et classe l'erreur éventuelle (entrée/sortie ou encodage),
+// sauf si elle l'est déjà (un paramètre refusé par l'encodeur)
+func encode(w io.Writer, format string, enc func(w io.Writer) error) error {
+	ew := &errWriter{w: w}
+	if err := enc(ew); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return err
+		}
+		if ew.err != nil {
+			return &Error{Kind: IOError, Op: "écriture " + format, Err: ew.err}
+		}
+		return &Error{Kind: EncodingError, Op: "encodage " + format, Err: err}
+	}
+	return nil
+}
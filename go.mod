@@ -8,5 +8,6 @@ require (
 	github.com/spf13/pflag v1.0.6-0.20201009195203-85dd5c8bc61c
 	github.com/tdewolff/canvas v0.0.0-20201021153214-d9228b138ea8
 	github.com/tdewolff/minify/v2 v2.9.5
-	golang.org/x/text v0.3.3 // indirect
+	golang.org/x/image v0.0.0-20200924062109-4578eab98f00
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,291 @@
+package marianne
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// Les profils ICC (version 2) des intentions de sortie des PDF/A et PDF/X.
+// Ils sont générés à partir des mêmes formules que celles utilisées pour les
+// couleurs du logo : sRGB pour le RVB, et la conversion simple de RVB en CMJN
+// (voir ColorTable) pour la quadrichromie.
+
+// le blanc D50 de l'espace de connexion des profils (PCS)
+var iccD50 = [3]float64{0.9642, 1.0, 0.8249}
+
+// les primaires sRGB adaptées au blanc D50 (les colonnes de la matrice RVB -> XYZ)
+var iccSRGBPrimaries = [3][3]float64{
+	{0.4361, 0.2225, 0.0139}, // rouge
+	{0.3851, 0.7169, 0.0971}, // vert
+	{0.1431, 0.0606, 0.7141}, // bleu
+}
+
+// un tag du profil : sa signature et son contenu (plusieurs tags peuvent partager le même contenu)
+type iccTag struct {
+	sig  string
+	data []byte
+}
+
+// écrit un nombre s15Fixed16
+func s15f16(b *bytes.Buffer, f float64) {
+	binary.Write(b, binary.BigEndian, int32(math.Round(f*65536)))
+}
+
+// le tag 'desc' (textDescriptionType)
+func iccDesc(s string) []byte {
+	var b bytes.Buffer
+	b.WriteString("desc\x00\x00\x00\x00")
+	binary.Write(&b, binary.BigEndian, uint32(len(s)+1))
+	b.WriteString(s + "\x00")
+	// pas de version Unicode ni ScriptCode
+	b.Write(make([]byte, 4+4+2+1+67))
+	return b.Bytes()
+}
+
+// le tag 'cprt' (textType)
+func iccText(s string) []byte {
+	return []byte("text\x00\x00\x00\x00" + s + "\x00")
+}
+
+// un tag XYZType
+func iccXYZ(xyz [3]float64) []byte {
+	var b bytes.Buffer
+	b.WriteString("XYZ \x00\x00\x00\x00")
+	for _, f := range xyz {
+		s15f16(&b, f)
+	}
+	return b.Bytes()
+}
+
+// un tag curveType avec la courbe de sRGB
+func iccSRGBCurve() []byte {
+	var b bytes.Buffer
+	b.WriteString("curv\x00\x00\x00\x00")
+	const n = 1024
+	binary.Write(&b, binary.BigEndian, uint32(n))
+	for i := 0; i < n; i++ {
+		binary.Write(&b, binary.BigEndian, uint16(math.Round(srgbToLinear(float64(i)/(n-1))*65535)))
+	}
+	return b.Bytes()
+}
+
+// un tag lut8Type : nin entrées, nout sorties, grid points par dimension,
+// et f qui calcule les sorties (entre 0 et 1) à partir des entrées (entre 0 et 1)
+func iccLut8(nin, nout, grid int, f func(in []float64) []float64) []byte {
+	var b bytes.Buffer
+	b.WriteString("mft1\x00\x00\x00\x00")
+	b.Write([]byte{byte(nin), byte(nout), byte(grid), 0})
+	// la matrice identité
+	for i := 0; i < 9; i++ {
+		if i%4 == 0 {
+			s15f16(&b, 1)
+		} else {
+			s15f16(&b, 0)
+		}
+	}
+	// les tables d'entrée (identité)
+	for i := 0; i < nin; i++ {
+		for v := 0; v < 256; v++ {
+			b.WriteByte(byte(v))
+		}
+	}
+	// la table de correspondance (la première entrée varie le plus lentement)
+	in := make([]float64, nin)
+	total := int(math.Pow(float64(grid), float64(nin)))
+	for n := 0; n < total; n++ {
+		for i, m := nin-1, n; i >= 0; i, m = i-1, m/grid {
+			in[i] = float64(m%grid) / float64(grid-1)
+		}
+		for _, v := range f(in) {
+			b.WriteByte(byte(math.Round(math.Max(0, math.Min(1, v)) * 255)))
+		}
+	}
+	// les tables de sortie (identité)
+	for i := 0; i < nout; i++ {
+		for v := 0; v < 256; v++ {
+			b.WriteByte(byte(v))
+		}
+	}
+	return b.Bytes()
+}
+
+// assemble le profil : en-tête de 128 octets, table des tags puis leurs contenus
+func iccProfile(class, space, pcs string, tags []iccTag) []byte {
+	var data bytes.Buffer
+	offsets := map[*byte]int{}
+	start := 128 + 4 + 12*len(tags)
+	type entry struct{ offset, size int }
+	var table []entry
+	for _, t := range tags {
+		if o, ok := offsets[&t.data[0]]; ok {
+			table = append(table, entry{o, len(t.data)})
+			continue
+		}
+		o := start + data.Len()
+		offsets[&t.data[0]] = o
+		table = append(table, entry{o, len(t.data)})
+		data.Write(t.data)
+		// les tags sont alignés sur 4 octets
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint32(start+data.Len()))
+	b.WriteString("\x00\x00\x00\x00")       // CMM
+	b.Write([]byte{2, 0x10, 0, 0})          // version 2.1
+	b.WriteString(class + space + pcs)      // classe, espace des données et PCS
+	b.Write([]byte{0x07, 0xe4, 0, 1, 0, 1}) // date : 2020-01-01
+	b.Write(make([]byte, 6))                // 00:00:00
+	b.WriteString("acsp")                   // signature
+	b.Write(make([]byte, 4+4+4+4+8+4))      // plateforme, options, fabricant, modèle, attributs, rendu
+	for _, f := range iccD50 {              // illuminant
+		s15f16(&b, f)
+	}
+	b.Write(make([]byte, 4+16+28)) // créateur, identifiant, réservé
+	binary.Write(&b, binary.BigEndian, uint32(len(tags)))
+	for i, t := range tags {
+		b.WriteString(t.sig)
+		binary.Write(&b, binary.BigEndian, uint32(table[i].offset))
+		binary.Write(&b, binary.BigEndian, uint32(table[i].size))
+	}
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+// srgbProfile retourne un profil ICC sRGB (pour l'intention de sortie des PDF/A en RVB)
+func srgbProfile() []byte {
+	trc := iccSRGBCurve()
+	return iccProfile("mntr", "RGB ", "XYZ ", []iccTag{
+		{"desc", iccDesc("sRGB IEC61966-2.1")},
+		{"cprt", iccText("No copyright, use freely")},
+		{"wtpt", iccXYZ(iccD50)},
+		{"rXYZ", iccXYZ(iccSRGBPrimaries[0])},
+		{"gXYZ", iccXYZ(iccSRGBPrimaries[1])},
+		{"bXYZ", iccXYZ(iccSRGBPrimaries[2])},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	})
+}
+
+// cmykProfile retourne un profil ICC d'impression en CMJN correspondant à la conversion
+// simple entre RVB et CMJN (pour l'intention de sortie des PDF/A en CMJN)
+func cmykProfile() []byte {
+	// CMJN -> Lab (par l'intermédiaire de sRGB)
+	a2b := iccLut8(4, 3, 9, func(in []float64) []float64 {
+		k := 1 - in[3]
+		return labToLut(xyzToLab(rgbToXYZ((1-in[0])*k, (1-in[1])*k, (1-in[2])*k)))
+	})
+	// Lab -> CMJN
+	b2a := iccLut8(3, 4, 17, func(in []float64) []float64 {
+		r, g, b := xyzToRGB(labToXYZ(lutToLab(in)))
+		k := 1 - math.Max(r, math.Max(g, b))
+		if k >= 1 {
+			return []float64{0, 0, 0, 1}
+		}
+		return []float64{(1 - r - k) / (1 - k), (1 - g - k) / (1 - k), (1 - b - k) / (1 - k), k}
+	})
+	// toutes les couleurs sont considérées dans le gamut
+	gamut := iccLut8(3, 1, 2, func(in []float64) []float64 { return []float64{0} })
+	return iccProfile("prtr", "CMYK", "Lab ", []iccTag{
+		{"desc", iccDesc("marianne CMJN")},
+		{"cprt", iccText("No copyright, use freely")},
+		{"wtpt", iccXYZ(iccD50)},
+		{"A2B0", a2b},
+		{"A2B1", a2b},
+		{"A2B2", a2b},
+		{"B2A0", b2a},
+		{"B2A1", b2a},
+		{"B2A2", b2a},
+		{"gamt", gamut},
+	})
+}
+
+// la courbe de sRGB (de la valeur codée vers la valeur linéaire) et son inverse
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// sRGB (entre 0 et 1) -> XYZ (D50)
+func rgbToXYZ(r, g, b float64) [3]float64 {
+	rgb := [3]float64{srgbToLinear(r), srgbToLinear(g), srgbToLinear(b)}
+	var xyz [3]float64
+	for i := range xyz {
+		for j := range rgb {
+			xyz[i] += iccSRGBPrimaries[j][i] * rgb[j]
+		}
+	}
+	return xyz
+}
+
+// XYZ (D50) -> sRGB (entre 0 et 1), en inversant la matrice des primaires
+func xyzToRGB(xyz [3]float64) (float64, float64, float64) {
+	m := iccSRGBPrimaries
+	// m[j][i] est la contribution de la primaire j à la composante i
+	a := [3][3]float64{
+		{m[0][0], m[1][0], m[2][0]},
+		{m[0][1], m[1][1], m[2][1]},
+		{m[0][2], m[1][2], m[2][2]},
+	}
+	det := a[0][0]*(a[1][1]*a[2][2]-a[1][2]*a[2][1]) - a[0][1]*(a[1][0]*a[2][2]-a[1][2]*a[2][0]) + a[0][2]*(a[1][0]*a[2][1]-a[1][1]*a[2][0])
+	inv := [3][3]float64{
+		{(a[1][1]*a[2][2] - a[1][2]*a[2][1]) / det, (a[0][2]*a[2][1] - a[0][1]*a[2][2]) / det, (a[0][1]*a[1][2] - a[0][2]*a[1][1]) / det},
+		{(a[1][2]*a[2][0] - a[1][0]*a[2][2]) / det, (a[0][0]*a[2][2] - a[0][2]*a[2][0]) / det, (a[0][2]*a[1][0] - a[0][0]*a[1][2]) / det},
+		{(a[1][0]*a[2][1] - a[1][1]*a[2][0]) / det, (a[0][1]*a[2][0] - a[0][0]*a[2][1]) / det, (a[0][0]*a[1][1] - a[0][1]*a[1][0]) / det},
+	}
+	var rgb [3]float64
+	for i := range rgb {
+		for j := range xyz {
+			rgb[i] += inv[i][j] * xyz[j]
+		}
+		rgb[i] = linearToSRGB(rgb[i])
+	}
+	return rgb[0], rgb[1], rgb[2]
+}
+
+// XYZ (D50) -> Lab et son inverse
+func xyzToLab(xyz [3]float64) [3]float64 {
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(xyz[0]/iccD50[0]), f(xyz[1]/iccD50[1]), f(xyz[2]/iccD50[2])
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+func labToXYZ(lab [3]float64) [3]float64 {
+	fy := (lab[0] + 16) / 116
+	fx, fz := fy+lab[1]/500, fy-lab[2]/200
+	f := func(t float64) float64 {
+		if t*t*t > 216.0/24389 {
+			return t * t * t
+		}
+		return (116*t - 16) * 27 / 24389
+	}
+	return [3]float64{f(fx) * iccD50[0], f(fy) * iccD50[1], f(fz) * iccD50[2]}
+}
+
+// le codage de Lab dans les lut8Type : L de 0 à 100 et a, b de -128 à 127 (ramenés entre 0 et 1)
+func labToLut(lab [3]float64) []float64 {
+	return []float64{lab[0] / 100, (lab[1] + 128) / 255, (lab[2] + 128) / 255}
+}
+
+func lutToLab(v []float64) [3]float64 {
+	return [3]float64{v[0] * 100, v[1]*255 - 128, v[2]*255 - 128}
+}
@@ -0,0 +1,78 @@
+package marianne
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// la largeur de la Marianne seule (voir la bbox de logo)
+const symbolWidth = 2756.0
+
+// RenderSymbol dessine uniquement la Marianne (sans textes ni devise), centrée dans un
+// carré, pour les favicons et les icônes d'applications. La Marianne occupe toute la largeur
+// du carré sauf la marge padding de chaque côté (en fraction du côté, entre 0 et 0.45).
+// Le fond suit opts comme pour Render.
+func RenderSymbol(opts Options, padding float64) *canvas.Canvas {
+	if padding < 0 {
+		padding = 0
+	} else if padding > 0.45 {
+		padding = 0.45
+	}
+	side := symbolWidth / (1 - 2*padding)
+	c := canvas.New(side, side)
+	ctx := canvas.NewContext(c)
+	logoPaths, _ := loadPaths()
+	colors := opts.colors()
+	for i := 0; i < 3; i++ {
+		ctx.SetFillColor(colors.marianne[i])
+		ctx.DrawPath(side*padding, side/2+x/2, logoPaths[i])
+	}
+	if opts.Transparent {
+		return c
+	}
+	return onBackground(c, opts.BackgroundColor())
+}
+
+// EncodeICO écrit les images imgs (de 256 pixels de côté au plus) dans un seul fichier
+// ICO, chacune enregistrée en PNG (comme le font les favicons multi-résolutions)
+func EncodeICO(w io.Writer, imgs []image.Image) error {
+	if len(imgs) == 0 {
+		return parameterError("aucune image pour le fichier ICO")
+	}
+	var entries [][]byte
+	for _, img := range imgs {
+		b := img.Bounds()
+		if b.Dx() > 256 || b.Dy() > 256 {
+			return parameterError("image trop grande pour le fichier ICO : %dx%d (au plus 256 pixels de côté)", b.Dx(), b.Dy())
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return &Error{Kind: EncodingError, Op: "encodage ICO", Err: err}
+		}
+		entries = append(entries, buf.Bytes())
+	}
+	return encode(w, "ICO", func(w io.Writer) error {
+		var buf bytes.Buffer
+		// l'en-tête : réservé, type (1 pour les icônes), nombre d'images
+		binary.Write(&buf, binary.LittleEndian, [3]uint16{0, 1, uint16(len(imgs))})
+		// le répertoire des images (la taille 256 s'écrit 0)
+		offset := 6 + 16*len(imgs)
+		for i, img := range imgs {
+			b := img.Bounds()
+			buf.Write([]byte{byte(b.Dx()), byte(b.Dy()), 0, 0})
+			binary.Write(&buf, binary.LittleEndian, [2]uint16{1, 32})
+			binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(len(entries[i])), uint32(offset)})
+			offset += len(entries[i])
+		}
+		for _, e := range entries {
+			buf.Write(e)
+		}
+		_, err := buf.WriteTo(w)
+		return err
+	})
+}
@@ -0,0 +1,70 @@
+package marianne
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestRenderSymbol(t *testing.T) {
+	for _, padding := range []float64{0, 0.1, 0.45} {
+		c := RenderSymbol(DefaultOptions(), padding)
+		if c.W != c.H {
+			t.Errorf("marge %g : icône de %gx%g au lieu d'un carré", padding, c.W, c.H)
+		}
+		// la Marianne occupe toute la largeur sauf les marges
+		if w := c.W * (1 - 2*padding); math.Abs(w-symbolWidth) > 1e-6 {
+			t.Errorf("marge %g : la Marianne fait %g au lieu de %g", padding, w, symbolWidth)
+		}
+	}
+	// la marge est limitée à 0.45
+	if a, b := RenderSymbol(DefaultOptions(), 0.9), RenderSymbol(DefaultOptions(), 0.45); a.W != b.W {
+		t.Errorf("marge 0.9 : côté %g au lieu de %g", a.W, b.W)
+	}
+}
+
+func TestEncodeICO(t *testing.T) {
+	sizes := []int{16, 32, 256}
+	var imgs []image.Image
+	for _, s := range sizes {
+		imgs = append(imgs, CanvasToImage(RenderSymbol(DefaultOptions(), 0.1), 0, uint(s), DefaultOptions()))
+	}
+	var buf bytes.Buffer
+	if err := EncodeICO(&buf, imgs); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	var header [3]uint16
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, &header)
+	if header != [3]uint16{0, 1, uint16(len(sizes))} {
+		t.Fatalf("en-tête %v", header)
+	}
+	for i, s := range sizes {
+		entry := data[6+16*i : 6+16*(i+1)]
+		// la taille 256 s'écrit 0
+		if w, h := int(entry[0]), int(entry[1]); w != s%256 || h != s%256 {
+			t.Errorf("image %d : taille %dx%d dans le répertoire au lieu de %d", i, w, h, s)
+		}
+		size := binary.LittleEndian.Uint32(entry[8:])
+		offset := binary.LittleEndian.Uint32(entry[12:])
+		img, err := png.Decode(bytes.NewReader(data[offset : offset+size]))
+		if err != nil {
+			t.Fatalf("image %d : %v", i, err)
+		}
+		if b := img.Bounds(); b.Dx() != s || b.Dy() != s {
+			t.Errorf("image %d : PNG de %dx%d au lieu de %d", i, b.Dx(), b.Dy(), s)
+		}
+	}
+}
+
+func TestEncodeICOErrors(t *testing.T) {
+	big := image.NewNRGBA(image.Rect(0, 0, 257, 257))
+	for name, imgs := range map[string][]image.Image{"vide": nil, "trop grande": {big}} {
+		if err := EncodeICO(&bytes.Buffer{}, imgs); KindOf(err) != ParameterError {
+			t.Errorf("%s : erreur %v au lieu d'une erreur de paramètre", name, err)
+		}
+	}
+}
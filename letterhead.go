@@ -0,0 +1,100 @@
+package marianne
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// les dimensions du papier à en-tête (en centièmes de mm, comme le logo)
+const (
+	// le format A4
+	a4Width, a4Height = 21000.0, 29700.0
+	// l'échelle du bloc-marque : la Marianne fait 4,5 mm de haut
+	letterheadScale = 0.45
+	// la distance entre le bloc-marque et les bords de la page (plus que sa zone de protection)
+	letterheadLogoMargin = 1000.0
+	// le pied de page : sa distance au bas de la page (10 mm), la hauteur du "A" (7 pt) et l'interligne
+	footerBottom, footerSize, footerStep = 1000.0, 173.0, 148.0
+)
+
+// LetterheadMargin est la marge du corps de la lettre à gauche et à droite (20 mm)
+const LetterheadMargin = 2000.0
+
+// RenderLetterhead dessine le papier à en-tête A4 : le bloc-marque en haut à gauche et,
+// si footer n'est pas vide, le pied de page (l'adresse...) en bas à gauche, dans la marge
+// du corps de la lettre. Les lignes du pied de page sont séparées comme celles de
+// l'institution (avec opts.EOL). Le corps de la lettre commence sous le bloc-marque
+// (voir LetterheadTop) avec des marges de LetterheadMargin à gauche et à droite.
+func RenderLetterhead(opts Options, footer string) (*canvas.Canvas, error) {
+	opts = opts.normalize()
+	// le bloc-marque seul (sans marges ni fond)
+	lo := opts
+	lo.NoMargins, lo.Transparent = true, true
+	logo, err := Render(lo)
+	if err != nil {
+		return nil, err
+	}
+
+	c := canvas.New(a4Width, a4Height)
+	ctx := canvas.NewContext(c)
+	if !opts.Transparent {
+		ctx.SetFillColor(opts.BackgroundColor())
+		ctx.DrawPath(0, 0, canvas.Rectangle(a4Width, a4Height))
+	}
+	ctx.SetView(canvas.Identity.Translate(letterheadLogoMargin, a4Height-letterheadLogoMargin-logo.H*letterheadScale).Scale(letterheadScale, letterheadScale))
+	logo.Render(ctx)
+	ctx.ResetView()
+
+	// le pied de page, aligné en bas
+	if footer = strings.TrimSpace(footer); footer != "" {
+		fontFamily, err := loadFont()
+		if err != nil {
+			return nil, &Error{Kind: FontError, Op: "chargement de la police Marianne", Err: err}
+		}
+		n := len(textLines(footer, opts.EOL))
+		top := a4Height - footerBottom - float64(n)*footerSize - float64(n-1)*footerStep
+		// drawText compte les ordonnées vers le bas depuis le haut de la page
+		ctx.SetView(canvas.Identity.Translate(0, a4Height))
+		drawText(ctx, fontFamily, opts.colors().text, footer, opts.EOL, LetterheadMargin, top, footerSize, footerStep, Wrap{})
+		ctx.ResetView()
+	}
+	return c, nil
+}
+
+// LetterheadTop retourne la distance (en centièmes de mm) entre le haut de la page et le
+// début du corps de la lettre : sous le bloc-marque et sa zone de protection
+func LetterheadTop(opts Options) (float64, error) {
+	lo := opts.normalize()
+	lo.NoMargins, lo.Transparent = true, true
+	logo, err := Render(lo)
+	if err != nil {
+		return 0, err
+	}
+	return letterheadLogoMargin + (logo.H+x)*letterheadScale, nil
+}
+
+// EncodePage écrit la page c (par exemple le papier à en-tête de RenderLetterhead) à sa
+// taille réelle, une unité valant 1/100 mm : en PDF (avec les couleurs d'impression et la
+// norme des options) ou en SVG (avec sa largeur et sa hauteur en mm)
+func EncodePage(w io.Writer, c *canvas.Canvas, format string, opts Options) error {
+	opts = opts.normalize()
+	switch format = NormalizeFormat(format); format {
+	case "pdf":
+		return encode(w, "PDF", func(w io.Writer) error { return writePDF(w, []Page{Page{Canvas: c}.inMillimeters()}, opts) })
+	case "svg":
+		return encode(w, "SVG", func(w io.Writer) error {
+			var buf bytes.Buffer
+			if err := writeSVG(&buf, c); err != nil {
+				return err
+			}
+			size := fmt.Sprintf(`<svg width="%smm" height="%smm" `, num(c.W/100), num(c.H/100))
+			_, err := io.WriteString(w, strings.Replace(buf.String(), "<svg ", size, 1))
+			return err
+		})
+	}
+	return parameterError("format de page inconnu : %q (PDF ou SVG)", format)
+}
@@ -0,0 +1,57 @@
+package marianne
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLetterhead(t *testing.T) {
+	plain, err := RenderLetterhead(DefaultOptions(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := RenderLetterhead(DefaultOptions(), "20 avenue de Ségur\\75007 Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.W != a4Width || c.H != a4Height {
+		t.Errorf("page de %gx%g au lieu de l'A4", c.W, c.H)
+	}
+
+	// le PDF et le SVG sont au format A4 : 210 x 297 mm
+	var buf bytes.Buffer
+	if err := EncodePage(&buf, c, "pdf", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if boxes := mediaBoxes(buf.Bytes()); len(boxes) != 1 || boxes[0] != "0 0 595.2756 841.8898" {
+		t.Errorf("MediaBox du papier à en-tête : %v", boxes)
+	}
+	var svg, plainSVG bytes.Buffer
+	if err := EncodePage(&svg, c, "svg", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(svg.String(), `<svg width="210mm" height="297mm" `) {
+		t.Errorf("SVG du papier à en-tête : %.60q", svg.String())
+	}
+	// le pied de page est dessiné
+	if err := EncodePage(&plainSVG, plain, "svg", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if svg.Len() <= plainSVG.Len() {
+		t.Errorf("le pied de page n'est pas dessiné (%d octets, %d sans pied de page)", svg.Len(), plainSVG.Len())
+	}
+
+	// le corps de la lettre commence sous le bloc-marque, dans le premier quart de la page
+	top, err := LetterheadTop(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top <= letterheadLogoMargin || top >= a4Height/4 {
+		t.Errorf("le corps de la lettre commence à %g", top)
+	}
+
+	if err := EncodePage(&buf, c, "png", Options{}); KindOf(err) != ParameterError {
+		t.Errorf("page en PNG : erreur %v au lieu d'une erreur de paramètre", err)
+	}
+}
@@ -0,0 +1,128 @@
+package marianne
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// Wrap décrit le passage à la ligne automatique d'un texte du logo
+type Wrap struct {
+	// La largeur maximale des lignes, en hauteurs de capitale (la hauteur du "A"), 0 pour aucune.
+	MaxWidth float64
+	// Le nombre de lignes (au plus, avec MaxWidth), 0 pour aucun.
+	MaxLines int
+}
+
+// le passage à la ligne automatique est demandé
+func (w Wrap) enabled() bool {
+	return w.MaxWidth > 0 || w.MaxLines > 0
+}
+
+// les mots courts après lesquels on ne passe pas à la ligne (articles et prépositions)
+var noBreakAfter = map[string]bool{
+	"le": true, "la": true, "les": true, "un": true, "une": true, "des": true, "du": true,
+	"de": true, "au": true, "aux": true, "à": true, "en": true, "et": true, "«": true,
+}
+
+// la ponctuation précédée d'une espace avant laquelle on ne passe pas à la ligne
+const noBreakBefore = ":;!?»"
+
+// breakChunks découpe la ligne en morceaux qui ne peuvent pas être coupés : les mots,
+// regroupés selon les règles françaises (pas de coupure avant ":" ni après un article)
+func breakChunks(line string) []string {
+	var chunks []string
+	glue := false
+	for _, w := range strings.Fields(line) {
+		if r, _ := utf8.DecodeRuneInString(w); len(chunks) > 0 && (glue || strings.ContainsRune(noBreakBefore, r)) {
+			chunks[len(chunks)-1] += " " + w
+		} else {
+			chunks = append(chunks, w)
+		}
+		glue = noBreakAfter[strings.ToLower(w)]
+	}
+	return chunks
+}
+
+// chunkWidths retourne la largeur des lignes formées des morceaux i à j-1 : chaque morceau
+// est mesuré une seule fois (les sommes cumulées de leurs largeurs), plus les espaces entre eux
+func chunkWidths(face canvas.FontFace, chunks []string) func(i, j int) float64 {
+	sums := make([]float64, len(chunks)+1)
+	for i, c := range chunks {
+		_, w := face.ToPath(c)
+		sums[i+1] = sums[i] + w
+	}
+	_, space := face.ToPath(" ")
+	return func(i, j int) float64 {
+		return sums[j] - sums[i] + float64(j-i-1)*space
+	}
+}
+
+// breakLines coupe la ligne en lignes équilibrées selon wrap, avec la largeur réelle des
+// glyphes de face : le plus petit nombre de lignes ne dépassant pas la largeur maximale
+// (size est la hauteur du "A"), ou le nombre de lignes demandé, en minimisant la plus
+// longue ligne puis l'écart entre les lignes
+func breakLines(face canvas.FontFace, line string, wrap Wrap, size float64) []string {
+	chunks := breakChunks(line)
+	k := len(chunks)
+	if k < 2 {
+		return chunks
+	}
+	width := chunkWidths(face, chunks)
+
+	maxLines := k
+	if wrap.MaxLines > 0 && wrap.MaxLines < k {
+		maxLines = wrap.MaxLines
+	}
+	// best[n][j] est le meilleur découpage des j premiers morceaux en n lignes
+	// (programmation dynamique) : la plus longue ligne puis la somme des carrés des largeurs
+	type cut struct {
+		max, squares float64
+		prev         int
+	}
+	best := make([][]cut, maxLines+1)
+	for n := range best {
+		best[n] = make([]cut, k+1)
+		for j := range best[n] {
+			best[n][j] = cut{math.Inf(1), math.Inf(1), -1}
+		}
+	}
+	best[0][0] = cut{0, 0, -1}
+	for n := 1; n <= maxLines; n++ {
+		for j := n; j <= k; j++ {
+			for i := n - 1; i < j; i++ {
+				p := best[n-1][i]
+				if math.IsInf(p.max, 1) {
+					continue
+				}
+				w := width(i, j)
+				c := cut{math.Max(p.max, w), p.squares + w*w, i}
+				if b := best[n][j]; c.max < b.max-1e-9 || (c.max <= b.max+1e-9 && c.squares < b.squares) {
+					best[n][j] = c
+				}
+			}
+		}
+	}
+
+	n := maxLines
+	if wrap.MaxWidth > 0 {
+		// le plus petit nombre de lignes qui ne dépassent pas la largeur maximale
+		for m := 1; m < maxLines; m++ {
+			if best[m][k].max <= wrap.MaxWidth*size {
+				n = m
+				break
+			}
+		}
+	}
+
+	// les lignes, en remontant les coupures
+	lines := make([]string, n)
+	for m, j := n, k; m > 0; m-- {
+		i := best[m][j].prev
+		lines[m-1] = strings.Join(chunks[i:j], " ")
+		j = i
+	}
+	return lines
+}
@@ -0,0 +1,84 @@
+package marianne
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+func TestBreakChunks(t *testing.T) {
+	tests := []struct {
+		line   string
+		chunks []string
+	}{
+		{"Direction générale", []string{"Direction", "générale"}},
+		{"Service de la communication", []string{"Service", "de la communication"}},
+		{"Mission « Europe » : avenir", []string{"Mission", "« Europe » :", "avenir"}},
+		{"Pôle innovation »", []string{"Pôle", "innovation »"}},
+		{"Quoi ? Pourquoi !", []string{"Quoi ?", "Pourquoi !"}},
+		{"à la une", []string{"à la une"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := breakChunks(tt.line); !reflect.DeepEqual(got, tt.chunks) {
+			t.Errorf("breakChunks(%q) = %q au lieu de %q", tt.line, got, tt.chunks)
+		}
+	}
+}
+
+func TestBreakLines(t *testing.T) {
+	fontFamily, err := loadFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := 11 * x / 20
+	face := fontFamily.Face(size*fontScale, canvas.Black, canvas.FontBold, canvas.FontNormal)
+	tests := []struct {
+		line  string
+		wrap  Wrap
+		lines []string
+	}{
+		{"Direction", Wrap{MaxLines: 3}, []string{"Direction"}},
+		{"Direction générale des entreprises", Wrap{MaxLines: 2}, []string{"Direction générale", "des entreprises"}},
+		{"Direction générale des entreprises", Wrap{MaxLines: 1}, []string{"Direction générale des entreprises"}},
+		{"Direction générale des entreprises", Wrap{MaxWidth: 1000}, []string{"Direction générale des entreprises"}},
+		{"Direction générale des entreprises", Wrap{MaxWidth: 1}, []string{"Direction", "générale", "des entreprises"}},
+		{"Délégation « Vivre ensemble »", Wrap{MaxLines: 3}, []string{"Délégation", "« Vivre", "ensemble »"}},
+		{"Mission de la jeunesse : avenir", Wrap{MaxLines: 2}, []string{"Mission", "de la jeunesse : avenir"}},
+	}
+	for _, tt := range tests {
+		got := breakLines(face, tt.line, tt.wrap, size)
+		if !reflect.DeepEqual(got, tt.lines) {
+			t.Errorf("breakLines(%q, %+v) = %q au lieu de %q", tt.line, tt.wrap, got, tt.lines)
+		}
+		// aucune ligne ne commence par une ponctuation qui doit rester sur la ligne précédente
+		for _, l := range got {
+			if r, _ := utf8.DecodeRuneInString(l); strings.ContainsRune(noBreakBefore, r) {
+				t.Errorf("breakLines(%q) : la ligne %q commence par une ponctuation", tt.line, l)
+			}
+		}
+	}
+}
+
+func TestChunkWidths(t *testing.T) {
+	fontFamily, err := loadFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	face := fontFamily.Face(11*x/20*fontScale, canvas.Black, canvas.FontBold, canvas.FontNormal)
+	chunks := breakChunks("Direction générale de la sécurité « civile » et de la gestion des crises")
+	width := chunkWidths(face, chunks)
+	// la somme des largeurs est celle de la ligne mesurée d'un coup (au crénage près)
+	for i := 0; i < len(chunks); i++ {
+		for j := i + 1; j <= len(chunks); j++ {
+			_, want := face.ToPath(strings.Join(chunks[i:j], " "))
+			if got := width(i, j); math.Abs(got-want) > want/100 {
+				t.Errorf("largeur de %q : %g au lieu de %g", strings.Join(chunks[i:j], " "), got, want)
+			}
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package marianne
 
 import "image/color"
 
@@ -0,0 +1,363 @@
+package marianne
+
+import (
+	"encoding/xml"
+	"image/color"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// Partner est le logo d'un partenaire (externe) pour le co-marquage, lu avec ParsePartnerSVG
+type Partner struct {
+	// La taille du logo (celle de la viewBox du SVG).
+	W, H float64
+	// les formes remplies, dans les coordonnées du logo (l'origine en bas à gauche)
+	shapes []partnerShape
+}
+
+// une forme remplie du logo partenaire
+type partnerShape struct {
+	path *canvas.Path
+	col  color.RGBA
+}
+
+// l'état graphique hérité par les éléments du SVG
+type svgState struct {
+	m        canvas.Matrix
+	fill     string
+	fillRule string
+	opacity  float64 // l'opacité du groupe multipliée par celle du remplissage
+}
+
+// les éléments du SVG qui ne sont pas dessinés (avec leur contenu)
+var svgSkipped = map[string]bool{"defs": true, "clipPath": true, "mask": true, "symbol": true, "marker": true,
+	"title": true, "desc": true, "metadata": true, "style": true, "script": true,
+	"linearGradient": true, "radialGradient": true, "pattern": true, "filter": true}
+
+// ParsePartnerSVG lit le logo d'un partenaire en SVG. Seules les formes remplies (path,
+// rect, circle, ellipse, polygon, polyline) sont gardées, en couleurs unies : les textes doivent
+// être vectorisés, et les images matricielles, les dégradés et les éléments <use> ne
+// sont pas acceptés. Les contours (stroke) sont ignorés.
+func ParsePartnerSVG(r io.Reader) (*Partner, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	var (
+		p       = &Partner{}
+		stack   = []svgState{{m: canvas.Identity, fill: "black", fillRule: "nonzero", opacity: 1}}
+		skip    int // la profondeur dans un élément ignoré
+		viewBox []float64
+		root    = true
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, parameterError("logo partenaire : SVG invalide (%v)", err)
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+			} else if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.StartElement:
+			name := t.Name.Local
+			if skip > 0 || svgSkipped[name] {
+				skip++
+				continue
+			}
+			attrs := svgAttrs(t.Attr)
+			if attrs["display"] == "none" || attrs["visibility"] == "hidden" {
+				skip++
+				continue
+			}
+			switch name {
+			case "text", "image", "use", "foreignObject":
+				return nil, parameterError("logo partenaire : l'élément <%s> n'est pas accepté (les textes doivent être vectorisés)", name)
+			}
+			s, err := stack[len(stack)-1].apply(attrs)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, s)
+			if name == "svg" && root {
+				// la taille du logo : la viewBox, sinon la largeur et la hauteur
+				root = false
+				viewBox = svgNumbers(attrs["viewBox"])
+				if len(viewBox) != 4 {
+					w, _ := svgLength(attrs["width"])
+					h, _ := svgLength(attrs["height"])
+					viewBox = []float64{0, 0, w, h}
+				}
+				continue
+			}
+			path := svgShape(name, attrs)
+			if path == nil || path.Empty() || s.fill == "none" || s.fill == "transparent" {
+				continue
+			}
+			col, err := svgColor(s.fill)
+			if err != nil {
+				return nil, err
+			}
+			col.A = uint8(math.Round(s.opacity * 255))
+			col.R = uint8(math.Round(float64(col.R) * s.opacity))
+			col.G = uint8(math.Round(float64(col.G) * s.opacity))
+			col.B = uint8(math.Round(float64(col.B) * s.opacity))
+			if s.fillRule == "evenodd" {
+				path = evenOddToNonZero(path)
+			}
+			p.shapes = append(p.shapes, partnerShape{path.Transform(s.m), col})
+		}
+	}
+	if len(p.shapes) == 0 {
+		return nil, parameterError("logo partenaire : aucune forme remplie")
+	}
+
+	// sans taille, le logo est réduit à ses formes
+	if viewBox == nil || viewBox[2] <= 0 || viewBox[3] <= 0 {
+		b := p.shapes[0].path.Bounds()
+		for _, s := range p.shapes[1:] {
+			b = b.Add(s.path.Bounds())
+		}
+		viewBox = []float64{b.X, b.Y, b.W, b.H}
+	}
+	// le SVG a l'axe des ordonnées vers le bas, le logo vers le haut
+	p.W, p.H = viewBox[2], viewBox[3]
+	if !(p.W > 0 && p.H > 0) {
+		// le logo serait agrandi à l'infini
+		return nil, parameterError("logo partenaire : taille nulle (%gx%g)", p.W, p.H)
+	}
+	flip := canvas.Identity.Translate(-viewBox[0], viewBox[1]+viewBox[3]).Scale(1, -1)
+	for i := range p.shapes {
+		p.shapes[i].path = p.shapes[i].path.Transform(flip)
+	}
+	return p, nil
+}
+
+// Colors retourne les couleurs (différentes) du logo partenaire
+func (p *Partner) Colors() []color.RGBA {
+	var cols []color.RGBA
+	seen := map[color.RGBA]bool{}
+	for _, s := range p.shapes {
+		if !seen[s.col] {
+			seen[s.col] = true
+			cols = append(cols, s.col)
+		}
+	}
+	return cols
+}
+
+// draw dessine le logo partenaire dans ctx avec les couleurs transformées par col
+func (p *Partner) draw(ctx *canvas.Context, col func(color.RGBA) color.RGBA) {
+	for _, s := range p.shapes {
+		ctx.SetFillColor(col(s.col))
+		ctx.DrawPath(0, 0, s.path)
+	}
+}
+
+// evenOddToNonZero réoriente les contours de la forme p, remplie avec la règle pair-impair,
+// pour qu'elle soit remplie de la même façon avec la règle non nulle (la seule du rendu
+// matriciel) : les contours remplis dans le sens direct et les trous dans l'autre sens
+func evenOddToNonZero(p *canvas.Path) *canvas.Path {
+	// les contours aplatis en segments (les arcs faussent le calcul de Filling)
+	flat := p.Flatten()
+	fillings, flats := flat.Filling(canvas.EvenOdd), flat.Split()
+	q := &canvas.Path{}
+	for i, sp := range p.Split() {
+		if flats[i].CCW() != fillings[i] {
+			sp = sp.Reverse()
+		}
+		q = q.Append(sp)
+	}
+	return q
+}
+
+// les attributs d'un élément, complétés (et remplacés) par ceux de son style
+func svgAttrs(attr []xml.Attr) map[string]string {
+	m := map[string]string{}
+	for _, a := range attr {
+		m[a.Name.Local] = strings.TrimSpace(a.Value)
+	}
+	for _, decl := range strings.Split(m["style"], ";") {
+		if kv := strings.SplitN(decl, ":", 2); len(kv) == 2 {
+			m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return m
+}
+
+// l'état graphique d'un élément : celui de son parent modifié par ses attributs
+func (s svgState) apply(attrs map[string]string) (svgState, error) {
+	if v := attrs["fill"]; v != "" && v != "inherit" {
+		s.fill = v
+	}
+	if v := attrs["fill-rule"]; v != "" && v != "inherit" {
+		s.fillRule = v
+	}
+	for _, k := range []string{"opacity", "fill-opacity"} {
+		if v := attrs[k]; v != "" {
+			o, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return s, parameterError("logo partenaire : %s invalide %q", k, v)
+			}
+			s.opacity *= math.Max(0, math.Min(1, o))
+		}
+	}
+	if v := attrs["transform"]; v != "" {
+		m, err := svgTransform(v)
+		if err != nil {
+			return s, err
+		}
+		s.m = s.m.Mul(m)
+	}
+	return s, nil
+}
+
+// les fonctions de transformation : translate(...), scale(...), matrix(...)...
+var svgTransformRe = regexp.MustCompile(`(\w+)\s*\(([^)]*)\)`)
+
+// la matrice de l'attribut transform
+func svgTransform(v string) (canvas.Matrix, error) {
+	m := canvas.Identity
+	for _, f := range svgTransformRe.FindAllStringSubmatch(v, -1) {
+		a := svgNumbers(f[2])
+		arg := func(i int, def float64) float64 {
+			if i < len(a) {
+				return a[i]
+			}
+			return def
+		}
+		switch f[1] {
+		case "matrix":
+			if len(a) != 6 {
+				return m, parameterError("logo partenaire : transformation invalide %q", f[0])
+			}
+			m = m.Mul(canvas.Matrix{{a[0], a[2], a[4]}, {a[1], a[3], a[5]}})
+		case "translate":
+			m = m.Translate(arg(0, 0), arg(1, 0))
+		case "scale":
+			m = m.Scale(arg(0, 1), arg(1, arg(0, 1)))
+		case "rotate":
+			m = m.RotateAbout(arg(0, 0), arg(1, 0), arg(2, 0))
+		case "skewX":
+			m = m.Shear(math.Tan(arg(0, 0)*math.Pi/180), 0)
+		case "skewY":
+			m = m.Shear(0, math.Tan(arg(0, 0)*math.Pi/180))
+		default:
+			return m, parameterError("logo partenaire : transformation inconnue %q", f[0])
+		}
+	}
+	return m, nil
+}
+
+// le chemin d'une forme (nil si l'élément n'en est pas une)
+func svgShape(name string, attrs map[string]string) *canvas.Path {
+	num := func(k string) float64 {
+		f, _ := svgLength(attrs[k])
+		return f
+	}
+	switch name {
+	case "path":
+		p, err := canvas.ParseSVG(attrs["d"])
+		if err != nil {
+			return nil
+		}
+		return p
+	case "rect":
+		rx, ry := num("rx"), num("ry")
+		if rx == 0 {
+			rx = ry
+		}
+		return canvas.RoundedRectangle(num("width"), num("height"), math.Min(rx, math.Min(num("width"), num("height"))/2)).Translate(num("x"), num("y"))
+	case "circle":
+		return canvas.Circle(num("r")).Translate(num("cx"), num("cy"))
+	case "ellipse":
+		return canvas.Ellipse(num("rx"), num("ry")).Translate(num("cx"), num("cy"))
+	case "polygon", "polyline":
+		a := svgNumbers(attrs["points"])
+		if len(a) < 6 {
+			return nil
+		}
+		p := &canvas.Path{}
+		p.MoveTo(a[0], a[1])
+		for i := 2; i+1 < len(a); i += 2 {
+			p.LineTo(a[i], a[i+1])
+		}
+		p.Close()
+		return p
+	}
+	return nil
+}
+
+// les nombres d'une liste séparée par des espaces ou des virgules
+func svgNumbers(v string) []float64 {
+	var a []float64
+	for _, f := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' }) {
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil
+		}
+		a = append(a, n)
+	}
+	return a
+}
+
+// une longueur SVG en pixels (les pourcentages ne sont pas acceptés)
+func svgLength(v string) (float64, bool) {
+	v = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "px"))
+	f, err := strconv.ParseFloat(v, 64)
+	return f, err == nil
+}
+
+// les couleurs nommées les plus courantes
+var svgNamedColors = map[string]string{
+	"black": "#000", "white": "#fff", "red": "#f00", "lime": "#0f0", "green": "#008000", "blue": "#00f",
+	"yellow": "#ff0", "cyan": "#0ff", "aqua": "#0ff", "magenta": "#f0f", "fuchsia": "#f0f",
+	"gray": "#808080", "grey": "#808080", "silver": "#c0c0c0", "maroon": "#800000", "navy": "#000080",
+	"olive": "#808000", "purple": "#800080", "teal": "#008080", "orange": "#ffa500", "currentcolor": "#000",
+}
+
+// la couleur d'un remplissage : #rgb, #rrggbb, rgb(r, g, b) ou un nom
+func svgColor(v string) (color.RGBA, error) {
+	s := strings.ToLower(strings.TrimSpace(v))
+	if n, ok := svgNamedColors[s]; ok {
+		s = n
+	}
+	if strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")") {
+		var c [3]uint8
+		parts := strings.Split(s[4:len(s)-1], ",")
+		if len(parts) != 3 {
+			return color.RGBA{}, parameterError("logo partenaire : couleur invalide %q", v)
+		}
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			scale := 1.0
+			if strings.HasSuffix(part, "%") {
+				part, scale = strings.TrimSuffix(part, "%"), 2.55
+			}
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return color.RGBA{}, parameterError("logo partenaire : couleur invalide %q", v)
+			}
+			c[i] = uint8(math.Round(math.Max(0, math.Min(255, f*scale))))
+		}
+		return color.RGBA{c[0], c[1], c[2], 0xff}, nil
+	}
+	if strings.HasPrefix(s, "#") {
+		if c, err := ParseColor(s); err == nil {
+			return c, nil
+		}
+	}
+	if strings.HasPrefix(s, "url(") {
+		return color.RGBA{}, parameterError("logo partenaire : les dégradés et motifs ne sont pas acceptés (%s)", v)
+	}
+	return color.RGBA{}, parameterError("logo partenaire : couleur inconnue %q", v)
+}
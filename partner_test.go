@@ -0,0 +1,93 @@
+package marianne
+
+import (
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// parsePartnerFile lit le logo partenaire testdata/partenaire/name
+func parsePartnerFile(t *testing.T, name string) (*Partner, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "partenaire", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return ParsePartnerSVG(f)
+}
+
+// sameRect indique si les rectangles a et b sont égaux (aux arrondis près)
+func sameRect(a, b canvas.Rect) bool {
+	return math.Abs(a.X-b.X) < 1e-6 && math.Abs(a.Y-b.Y) < 1e-6 && math.Abs(a.W-b.W) < 1e-6 && math.Abs(a.H-b.H) < 1e-6
+}
+
+func TestParsePartnerSVG(t *testing.T) {
+	tests := []struct {
+		file   string
+		w, h   float64
+		bounds []canvas.Rect // les formes gardées, l'origine en bas à gauche
+		colors []color.RGBA
+	}{
+		{"simple.svg", 100, 50,
+			[]canvas.Rect{{X: 10, Y: 25, W: 30, H: 20}, {X: 60, Y: 15, W: 20, H: 20}},
+			[]color.RGBA{{0xe1, 0, 0x0f, 0xff}, {0, 0, 145, 0xff}}},
+		{"groupes.svg", 60, 40,
+			[]canvas.Rect{{X: 20, Y: 15, W: 10, H: 10}, {X: 30, Y: 10, W: 20, H: 20}},
+			[]color.RGBA{{0, 0, 64, 128}, {0, 0, 0, 0xff}}},
+		{"sans-viewbox.svg", 40, 20,
+			[]canvas.Rect{{X: 0, Y: 0, W: 40, H: 20}},
+			[]color.RGBA{{0, 0x80, 0, 0xff}}},
+	}
+	for _, tt := range tests {
+		p, err := parsePartnerFile(t, tt.file)
+		if err != nil {
+			t.Fatalf("%s : %v", tt.file, err)
+		}
+		if p.W != tt.w || p.H != tt.h {
+			t.Errorf("%s : taille %gx%g au lieu de %gx%g", tt.file, p.W, p.H, tt.w, tt.h)
+		}
+		if len(p.shapes) != len(tt.bounds) {
+			t.Fatalf("%s : %d formes au lieu de %d", tt.file, len(p.shapes), len(tt.bounds))
+		}
+		for i, s := range p.shapes {
+			if b := s.path.Bounds(); !sameRect(b, tt.bounds[i]) {
+				t.Errorf("%s : la forme %d occupe %v au lieu de %v", tt.file, i, b, tt.bounds[i])
+			}
+		}
+		if cols := p.Colors(); !reflect.DeepEqual(cols, tt.colors) {
+			t.Errorf("%s : couleurs %v au lieu de %v", tt.file, cols, tt.colors)
+		}
+	}
+}
+
+func TestParsePartnerSVGEvenOdd(t *testing.T) {
+	p, err := parsePartnerFile(t, "groupes.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// le carré troué, rempli avec la règle non nulle comme avec la règle pair-impair du SVG
+	path := p.shapes[1].path
+	if !path.Interior(32, 28, canvas.NonZero) {
+		t.Error("le bord du carré devrait être rempli")
+	}
+	if path.Interior(40, 20, canvas.NonZero) {
+		t.Error("le trou du carré ne devrait pas être rempli")
+	}
+}
+
+func TestParsePartnerSVGErrors(t *testing.T) {
+	for _, file := range []string{"texte.svg", "degrade.svg", "vide.svg", "plat.svg"} {
+		_, err := parsePartnerFile(t, file)
+		if err == nil {
+			t.Errorf("%s : une erreur était attendue", file)
+		} else if KindOf(err) != ParameterError {
+			t.Errorf("%s : erreur %v de type %v au lieu d'une erreur de paramètre", file, err, KindOf(err))
+		}
+	}
+}
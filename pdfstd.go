@@ -0,0 +1,202 @@
+package marianne
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// PDFStandard est la norme à laquelle les PDF sont conformes
+type PDFStandard string
+
+// les normes PDF
+const (
+	// pas de norme particulière
+	PDFStandardNone PDFStandard = ""
+	// PDF/A-2b pour l'archivage (la GED)
+	PDFA2b PDFStandard = "pdfa-2b"
+	// PDF/X-4 pour l'envoi aux imprimeurs (toujours en CMJN)
+	PDFX4 PDFStandard = "pdfx-4"
+)
+
+// ParsePDFStandard retourne la norme PDF correspondant au nom (vide pour aucune norme)
+func ParsePDFStandard(name string) (PDFStandard, error) {
+	n := strings.NewReplacer("/", "", "-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+	switch n {
+	case "", "aucune":
+		return PDFStandardNone, nil
+	case "pdfa", "pdfa2b":
+		return PDFA2b, nil
+	case "pdfx", "pdfx4":
+		return PDFX4, nil
+	}
+	return "", parameterError("norme PDF inconnue %q (pdfa-2b ou pdfx-4)", name)
+}
+
+// le type de l'intention de sortie de la norme
+func (s PDFStandard) intentSubtype() string {
+	if s == PDFX4 {
+		return "GTS_PDFX"
+	}
+	return "GTS_PDFA1"
+}
+
+// outputProfile retourne le profil ICC de l'intention de sortie (nil sans norme) : celui
+// des options s'il est donné (il doit correspondre aux couleurs du PDF), sinon un profil généré
+// (seulement pour le PDF/A : le PDF/X demande un vrai profil d'impression)
+func (opts Options) outputProfile() ([]byte, error) {
+	if opts.PDFStandard == PDFStandardNone {
+		return nil, nil
+	}
+	space := "RGB "
+	if opts.CMYK {
+		space = "CMYK"
+	}
+	p := opts.ICCProfile
+	if p == nil {
+		if opts.PDFStandard == PDFX4 {
+			// un profil généré n'est la caractérisation d'aucune condition d'impression reconnue
+			return nil, parameterError("le PDF/X-4 demande le profil ICC de la condition d'impression (par exemple Coated FOGRA39 ou PSO Coated v3)")
+		}
+		if opts.CMYK {
+			return cmykProfile(), nil
+		}
+		return srgbProfile(), nil
+	}
+	if len(p) < 128 || string(p[36:40]) != "acsp" {
+		return nil, parameterError("le profil ICC n'est pas valide")
+	}
+	if string(p[16:20]) != space {
+		return nil, parameterError("le profil ICC doit être en %s pour ce PDF (et non en %s)", strings.TrimSpace(space), strings.TrimSpace(string(p[16:20])))
+	}
+	if opts.PDFStandard == PDFX4 && string(p[12:16]) != "prtr" {
+		return nil, parameterError("le profil ICC d'un PDF/X-4 doit être un profil d'impression")
+	}
+	return p, nil
+}
+
+// le nom de la condition d'impression de l'intention de sortie
+func (opts Options) outputCondition() string {
+	switch {
+	case opts.ICCProfile != nil:
+		return "Custom"
+	case opts.CMYK:
+		return "marianne CMJN"
+	}
+	return "sRGB IEC61966-2.1"
+}
+
+// oneLine met le texte s sur une seule ligne (les passages à la ligne, standards ou eol,
+// deviennent des espaces)
+func oneLine(s, eol string) string {
+	if eol != "" {
+		s = strings.ReplaceAll(s, eol, " ")
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Title retourne le titre du logo : l'institution et la direction sur une seule ligne
+// (pour les métadonnées et le texte alternatif des images)
+func (opts Options) Title() string {
+	t := oneLine(opts.Institution, opts.EOL)
+	if d := oneLine(opts.Direction, opts.EOL); d != "" {
+		t += " – " + d
+	}
+	return t
+}
+
+// les métadonnées d'un PDF
+type pdfMeta struct {
+	title, creator string
+	date           time.Time
+	// l'identifiant du document (dans le trailer et en XMP)
+	id [16]byte
+}
+
+// newPDFMeta prépare les métadonnées du PDF dont le contenu est content
+func newPDFMeta(opts Options, content []byte) pdfMeta {
+	m := pdfMeta{title: opts.Title(), creator: opts.Creator, date: time.Now().Truncate(time.Second)}
+	m.id = md5.Sum(append([]byte(m.title+m.creator+m.date.String()), content...))
+	return m
+}
+
+// une chaîne de texte PDF (en UTF-16 si elle n'est pas en ASCII)
+func pdfText(s string) string {
+	for _, r := range s {
+		if r >= 0x80 {
+			var b strings.Builder
+			b.WriteString("<FEFF")
+			for _, u := range utf16.Encode([]rune(s)) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			return b.String() + ">"
+		}
+	}
+	return psString(s)
+}
+
+// la date au format PDF : D:AAAAMMJJHHmmSS+HH'mm'
+func pdfDate(t time.Time) string {
+	_, off := t.Zone()
+	sign := '+'
+	if off < 0 {
+		sign, off = '-', -off
+	}
+	return fmt.Sprintf("(D:%s%c%02d'%02d')", t.Format("20060102150405"), sign, off/3600, off%3600/60)
+}
+
+// le dictionnaire Info
+func (m pdfMeta) info() string {
+	date := pdfDate(m.date)
+	return fmt.Sprintf("<< /Title %s /Creator %s /Producer (marianne) /CreationDate %s /ModDate %s /Trapped /False >>",
+		pdfText(m.title), pdfText(m.creator), date, date)
+}
+
+// l'identifiant sous forme d'UUID
+func (m pdfMeta) uuid() string {
+	x := fmt.Sprintf("%x", m.id)
+	return "uuid:" + x[:8] + "-" + x[8:12] + "-" + x[12:16] + "-" + x[16:20] + "-" + x[20:]
+}
+
+// échappe un texte pour XML
+func xmlText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// xmp retourne les métadonnées XMP (les mêmes que celles du dictionnaire Info)
+// avec l'identification de la norme s
+func (m pdfMeta) xmp(s PDFStandard) []byte {
+	date := m.date.Format("2006-01-02T15:04:05-07:00")
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\"\n xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\"\n xmlns:xmpMM=\"http://ns.adobe.com/xap/1.0/mm/\"")
+	switch s {
+	case PDFA2b:
+		b.WriteString("\n xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\"")
+	case PDFX4:
+		b.WriteString("\n xmlns:pdfxid=\"http://www.npes.org/pdfx/ns/id/\"")
+	}
+	b.WriteString(">\n")
+	b.WriteString("<dc:format>application/pdf</dc:format>\n")
+	fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", xmlText(m.title))
+	fmt.Fprintf(&b, "<xmp:CreatorTool>%s</xmp:CreatorTool>\n", xmlText(m.creator))
+	fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n<xmp:ModifyDate>%s</xmp:ModifyDate>\n<xmp:MetadataDate>%s</xmp:MetadataDate>\n", date, date, date)
+	b.WriteString("<pdf:Producer>marianne</pdf:Producer>\n<pdf:Trapped>False</pdf:Trapped>\n")
+	fmt.Fprintf(&b, "<xmpMM:DocumentID>%s</xmpMM:DocumentID>\n<xmpMM:InstanceID>%s</xmpMM:InstanceID>\n", m.uuid(), m.uuid())
+	b.WriteString("<xmpMM:VersionID>1</xmpMM:VersionID>\n<xmpMM:RenditionClass>default</xmpMM:RenditionClass>\n")
+	switch s {
+	case PDFA2b:
+		b.WriteString("<pdfaid:part>2</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n")
+	case PDFX4:
+		b.WriteString("<pdfxid:GTS_PDFXVersion>PDF/X-4</pdfxid:GTS_PDFXVersion>\n")
+	}
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	// de la place pour modifier les métadonnées sur place
+	b.WriteString(strings.Repeat(strings.Repeat(" ", 99)+"\n", 20))
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
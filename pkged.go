@@ -2,7 +2,7 @@
 
 // +build !skippkger
 
-package main
+package marianne
 
 import (
 	"github.com/markbates/pkger"
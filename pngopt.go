@@ -0,0 +1,425 @@
+package marianne
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// les niveaux d'optimisation des PNG (voir Options.PNGOptimization)
+const (
+	// l'encodage par défaut de image/png
+	PNGOptimizationNone = 0
+	// la compression maximale de image/png
+	PNGOptimizationFast = 1
+	// essaie aussi toutes les stratégies de filtrage et les réductions de couleurs sans perte
+	PNGOptimizationMax = 2
+)
+
+// la signature des fichiers PNG
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// les types de couleurs des PNG
+const (
+	pngGray      = 0
+	pngRGB       = 2
+	pngPalette   = 3
+	pngGrayAlpha = 4
+	pngRGBA      = 6
+)
+
+// les stratégies de filtrage des lignes : un des 5 filtres pour toutes les lignes,
+// ou le meilleur filtre de chaque ligne (filterAdaptive)
+const (
+	filterNone = iota
+	filterSub
+	filterUp
+	filterAverage
+	filterPaeth
+	filterAdaptive
+)
+
+// writePNG écrit img en PNG avec le niveau d'optimisation level (voir OptimizePNG)
+func writePNG(w io.Writer, img image.Image, level int) error {
+	if level <= PNGOptimizationNone {
+		return png.Encode(w, img)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data, err := OptimizePNG(buf.Bytes(), level)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// OptimizePNG retourne le plus petit PNG sans perte équivalent au PNG data : avec le niveau
+// PNGOptimizationFast l'image est recompressée au maximum, et avec PNGOptimizationMax toutes
+// les stratégies de filtrage des lignes sont aussi essayées, ainsi que la plus petite
+// représentation des pixels (palette réduite aux couleurs utilisées avec le moins de bits
+// par pixel, palette pour les images de moins de 256 couleurs, niveaux de gris, sans couche
+// alpha pour les images opaques). Les images de 16 bits par composante restent en 16 bits
+// (sauf si toutes leurs valeurs tiennent sur 8 bits), et seule la couleur des pixels
+// entièrement transparents peut changer. Les blocs inutiles sont supprimés, sauf la
+// résolution (pHYs). Le PNG data est retourné tel quel s'il reste le plus petit.
+func OptimizePNG(data []byte, level int) ([]byte, error) {
+	if level <= PNGOptimizationNone {
+		return data, nil
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &Error{Kind: EncodingError, Op: "lecture du PNG à optimiser", Err: err}
+	}
+	best, phys := data, pngChunk(data, "pHYs")
+	try := func(candidate []byte) {
+		if phys != nil {
+			candidate = insertAfterIHDR(candidate, phys)
+		}
+		if len(candidate) < len(best) {
+			best = candidate
+		}
+	}
+
+	// la compression maximale de image/png
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, &Error{Kind: EncodingError, Op: "optimisation PNG", Err: err}
+	}
+	try(buf.Bytes())
+	if level < PNGOptimizationMax {
+		return best, nil
+	}
+
+	// toutes les représentations des pixels avec toutes les stratégies de filtrage
+	for _, r := range pngRepresentations(img) {
+		for f := filterNone; f <= filterAdaptive; f++ {
+			candidate, err := r.encode(f)
+			if err != nil {
+				return nil, &Error{Kind: EncodingError, Op: "optimisation PNG", Err: err}
+			}
+			try(candidate)
+		}
+	}
+	return best, nil
+}
+
+// une représentation des pixels d'une image PNG
+type pngImage struct {
+	width, height int
+	colorType     byte
+	depth         byte     // les bits par composante (ou par index de la palette)
+	plte, trns    []byte   // la palette et ses transparences (pour pngPalette)
+	rows          [][]byte // les lignes de pixels, non filtrées
+}
+
+// bytesPerPixel retourne le nombre d'octets d'un pixel (au moins 1), utilisé par les filtres
+func (p *pngImage) bytesPerPixel() int {
+	channels := map[byte]int{pngGray: 1, pngRGB: 3, pngPalette: 1, pngGrayAlpha: 2, pngRGBA: 4}[p.colorType]
+	if n := channels * int(p.depth) / 8; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// encode retourne le PNG de l'image avec la stratégie de filtrage strategy, compressé au maximum
+func (p *pngImage) encode(strategy int) ([]byte, error) {
+	var idat bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&idat, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	bpp := p.bytesPerPixel()
+	prev := make([]byte, len(p.rows[0]))
+	filtered := make([][]byte, filterPaeth+1)
+	for i := range filtered {
+		filtered[i] = make([]byte, 1+len(prev))
+	}
+	for _, row := range p.rows {
+		var out []byte
+		if strategy == filterAdaptive {
+			// le filtre dont la somme des valeurs absolues est la plus petite (comme libpng)
+			best := -1
+			for f := filterNone; f <= filterPaeth; f++ {
+				filterRow(filtered[f], row, prev, f, bpp)
+				if s := absSum(filtered[f][1:]); best < 0 || s < best {
+					best, out = s, filtered[f]
+				}
+			}
+		} else {
+			out = filtered[strategy]
+			filterRow(out, row, prev, strategy, bpp)
+		}
+		if _, err := zw.Write(out); err != nil {
+			return nil, err
+		}
+		prev = row
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(pngSignature)
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(p.width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(p.height))
+	ihdr[8], ihdr[9] = p.depth, p.colorType
+	writeChunk(&b, "IHDR", ihdr)
+	if p.colorType == pngPalette {
+		writeChunk(&b, "PLTE", p.plte)
+		if len(p.trns) > 0 {
+			writeChunk(&b, "tRNS", p.trns)
+		}
+	}
+	writeChunk(&b, "IDAT", idat.Bytes())
+	writeChunk(&b, "IEND", nil)
+	return b.Bytes(), nil
+}
+
+// filterRow écrit dans out le type du filtre f puis la ligne row filtrée (prev est la ligne précédente)
+func filterRow(out, row, prev []byte, f, bpp int) {
+	out[0] = byte(f)
+	for i := range row {
+		var a, b, c byte
+		if i >= bpp {
+			a, c = row[i-bpp], prev[i-bpp]
+		}
+		b = prev[i]
+		switch f {
+		case filterNone:
+			out[1+i] = row[i]
+		case filterSub:
+			out[1+i] = row[i] - a
+		case filterUp:
+			out[1+i] = row[i] - b
+		case filterAverage:
+			out[1+i] = row[i] - byte((int(a)+int(b))/2)
+		case filterPaeth:
+			out[1+i] = row[i] - paeth(a, b, c)
+		}
+	}
+}
+
+// paeth retourne le prédicteur de Paeth de a (à gauche), b (au-dessus) et c (en haut à gauche)
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// abs retourne la valeur absolue de n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// absSum retourne la somme des valeurs absolues des octets (vus comme signés)
+func absSum(b []byte) int {
+	s := 0
+	for _, v := range b {
+		s += abs(int(int8(v)))
+	}
+	return s
+}
+
+// writeChunk écrit le bloc PNG de type typ et de données data (avec sa longueur et son CRC)
+func writeChunk(b *bytes.Buffer, typ string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	b.Write(n[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	b.WriteString(typ)
+	b.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	b.Write(n[:])
+}
+
+// pngChunk retourne le premier bloc (complet) de type typ du PNG data, ou nil s'il n'y en a pas
+func pngChunk(data []byte, typ string) []byte {
+	for i := len(pngSignature); i+12 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		if i+12+n > len(data) {
+			return nil
+		}
+		if string(data[i+4:i+8]) == typ {
+			return data[i : i+12+n]
+		}
+		i += 12 + n
+	}
+	return nil
+}
+
+// insertAfterIHDR ajoute le bloc chunk juste après le bloc IHDR du PNG data
+func insertAfterIHDR(data, chunk []byte) []byte {
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4 // signature, puis longueur, type, données et CRC de IHDR
+	if len(data) < ihdrEnd {
+		return data
+	}
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+// pngPixels retourne les pixels de img en NRGBA sur 16 bits par composante (les images
+// de 8 bits ont des composantes multiples de 257), les pixels invisibles étant tous noirs
+func pngPixels(img image.Image) []color.NRGBA64 {
+	b := img.Bounds()
+	pix := make([]color.NRGBA64, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var c color.NRGBA64
+			switch img := img.(type) {
+			case *image.NRGBA64:
+				c = img.NRGBA64At(x, y)
+			case *image.Gray16:
+				g := img.Gray16At(x, y).Y
+				c = color.NRGBA64{g, g, g, 0xffff}
+			case *image.RGBA64:
+				// image/png ne donne des RGBA64 que pour les images opaques : la conversion est exacte
+				c = color.NRGBA64Model.Convert(img.RGBA64At(x, y)).(color.NRGBA64)
+			default:
+				c8 := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				c = color.NRGBA64{uint16(c8.R) * 257, uint16(c8.G) * 257, uint16(c8.B) * 257, uint16(c8.A) * 257}
+			}
+			if c.A == 0 {
+				c = color.NRGBA64{}
+			}
+			pix = append(pix, c)
+		}
+	}
+	return pix
+}
+
+// pngRepresentations retourne les représentations sans perte de img à essayer : la palette
+// réduite aux couleurs utilisées (si l'image a au plus 256 couleurs), puis les niveaux de gris
+// ou les couleurs RVB, avec la couche alpha seulement si l'image a de la transparence. Les
+// images de 16 bits par composante le restent, sauf si toutes leurs valeurs tiennent sur 8 bits.
+func pngRepresentations(img image.Image) []*pngImage {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return nil
+	}
+	pix := pngPixels(img)
+	opaque, gray, deep := true, true, false
+	for _, c := range pix {
+		opaque = opaque && c.A == 0xffff
+		gray = gray && c.R == c.G && c.G == c.B
+		deep = deep || c.R%257 != 0 || c.G%257 != 0 || c.B%257 != 0 || c.A%257 != 0
+	}
+	var reps []*pngImage
+	if !deep {
+		pix8 := make([]color.NRGBA, len(pix))
+		for i, c := range pix {
+			pix8[i] = color.NRGBA{uint8(c.R >> 8), uint8(c.G >> 8), uint8(c.B >> 8), uint8(c.A >> 8)}
+		}
+		if p := paletteRepresentation(pix8, w, h); p != nil {
+			reps = append(reps, p)
+		}
+	}
+	p := &pngImage{width: w, height: h, depth: 8}
+	if deep {
+		p.depth = 16
+	}
+	var channels []func(c color.NRGBA64) uint16
+	switch {
+	case gray:
+		p.colorType = pngGray
+		channels = append(channels, func(c color.NRGBA64) uint16 { return c.R })
+	default:
+		p.colorType = pngRGB
+		channels = append(channels,
+			func(c color.NRGBA64) uint16 { return c.R },
+			func(c color.NRGBA64) uint16 { return c.G },
+			func(c color.NRGBA64) uint16 { return c.B })
+	}
+	if !opaque {
+		p.colorType += 4 // pngGrayAlpha ou pngRGBA
+		channels = append(channels, func(c color.NRGBA64) uint16 { return c.A })
+	}
+	for y := 0; y < h; y++ {
+		row := make([]byte, 0, w*len(channels)*int(p.depth)/8)
+		for _, c := range pix[y*w : (y+1)*w] {
+			for _, ch := range channels {
+				if v := ch(c); deep {
+					row = append(row, byte(v>>8), byte(v))
+				} else {
+					row = append(row, byte(v>>8))
+				}
+			}
+		}
+		p.rows = append(p.rows, row)
+	}
+	return append(reps, p)
+}
+
+// paletteRepresentation retourne l'image en palette avec le moins de bits par pixel possible
+// (les couleurs transparentes en premier pour raccourcir tRNS), ou nil si elle a plus de 256 couleurs
+func paletteRepresentation(pix []color.NRGBA, w, h int) *pngImage {
+	index := map[color.NRGBA]int{}
+	var colors []color.NRGBA
+	for _, c := range pix {
+		if _, ok := index[c]; !ok {
+			if len(colors) == 256 {
+				return nil
+			}
+			index[c] = len(colors)
+			colors = append(colors, c)
+		}
+	}
+	// les couleurs transparentes en premier (dans l'ordre d'apparition)
+	var sorted []color.NRGBA
+	for _, opaque := range []bool{false, true} {
+		for _, c := range colors {
+			if (c.A == 0xff) == opaque {
+				index[c] = len(sorted)
+				sorted = append(sorted, c)
+			}
+		}
+	}
+	p := &pngImage{width: w, height: h, colorType: pngPalette, depth: 8}
+	switch n := len(sorted); {
+	case n <= 2:
+		p.depth = 1
+	case n <= 4:
+		p.depth = 2
+	case n <= 16:
+		p.depth = 4
+	}
+	for _, c := range sorted {
+		p.plte = append(p.plte, c.R, c.G, c.B)
+		if c.A != 0xff {
+			p.trns = append(p.trns, c.A)
+		}
+	}
+	perByte := 8 / int(p.depth)
+	for y := 0; y < h; y++ {
+		row := make([]byte, (w+perByte-1)/perByte)
+		for x, c := range pix[y*w : (y+1)*w] {
+			shift := uint(8 - int(p.depth)*(x%perByte+1))
+			row[x/perByte] |= byte(index[c]) << shift
+		}
+		p.rows = append(p.rows, row)
+	}
+	return p
+}
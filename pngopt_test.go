@@ -0,0 +1,153 @@
+package marianne
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// encodePNG écrit img en PNG avec image/png
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// samePixels vérifie que les PNG a et b ont les mêmes pixels (sur 16 bits par composante),
+// la couleur des pixels entièrement transparents mise à part
+func samePixels(t *testing.T, name string, a, b []byte) {
+	t.Helper()
+	ia, err := png.Decode(bytes.NewReader(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ib, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("%s : %v", name, err)
+	}
+	if ia.Bounds() != ib.Bounds() {
+		t.Fatalf("%s : taille %v au lieu de %v", name, ib.Bounds(), ia.Bounds())
+	}
+	r := ia.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			ca := color.NRGBA64Model.Convert(ia.At(x, y)).(color.NRGBA64)
+			cb := color.NRGBA64Model.Convert(ib.At(x, y)).(color.NRGBA64)
+			if ca.A == 0 && cb.A == 0 {
+				continue
+			}
+			if ca != cb {
+				t.Fatalf("%s : le pixel (%d, %d) est %v au lieu de %v", name, x, y, cb, ca)
+			}
+		}
+	}
+}
+
+// randomImage retourne une image de w x h pixels avec des couleurs aléatoires
+func randomImage(w, h int, set func(img *image.NRGBA64, x, y int, r *rand.Rand)) *image.NRGBA64 {
+	r := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA64(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			set(img, x, y, r)
+		}
+	}
+	return img
+}
+
+func TestOptimizePNG(t *testing.T) {
+	logo := func(opts Options) image.Image {
+		c, err := Render(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := CanvasToImage(c, 0, 120, opts)
+		if !opts.Transparent {
+			img = IndexImage(img, opts)
+		}
+		return img
+	}
+	gray16 := image.NewGray16(image.Rect(0, 0, 40, 30))
+	for i := range gray16.Pix {
+		gray16.Pix[i] = byte(i * 7)
+	}
+	images := map[string]image.Image{
+		"logo":         logo(Options{}),
+		"transparent":  logo(Options{Transparent: true}),
+		"256 couleurs": logo(Options{Colors: 256}),
+		"gris":         logo(Options{ColorMode: ColorModeGray}),
+		"gris 16 bits": gray16,
+		"16 bits": randomImage(40, 30, func(img *image.NRGBA64, x, y int, r *rand.Rand) {
+			img.SetNRGBA64(x, y, color.NRGBA64{uint16(r.Intn(65536)), uint16(r.Intn(65536)), uint16(r.Intn(65536)), uint16(r.Intn(65536))})
+		}),
+		"16 bits opaque": randomImage(40, 30, func(img *image.NRGBA64, x, y int, r *rand.Rand) {
+			img.SetNRGBA64(x, y, color.NRGBA64{uint16(r.Intn(65536)), uint16(x * 1000), uint16(y * 1000), 0xffff})
+		}),
+		"16 bits réduit": randomImage(40, 30, func(img *image.NRGBA64, x, y int, r *rand.Rand) {
+			img.SetNRGBA64(x, y, color.NRGBA64{uint16(x%4) * 257 * 80, 0, uint16(y%2) * 257 * 200, 0xffff})
+		}),
+	}
+	for name, img := range images {
+		data := encodePNG(t, img)
+		for level := PNGOptimizationNone; level <= PNGOptimizationMax; level++ {
+			out, err := OptimizePNG(data, level)
+			if err != nil {
+				t.Fatalf("%s niveau %d : %v", name, level, err)
+			}
+			if len(out) > len(data) {
+				t.Errorf("%s niveau %d : %d octets au lieu de %d au plus", name, level, len(out), len(data))
+			}
+			samePixels(t, name, data, out)
+		}
+	}
+}
+
+func TestOptimizePNGReduces(t *testing.T) {
+	// 4 couleurs sur 16 bits : la palette de 2 bits par pixel est bien plus petite
+	img := randomImage(64, 64, func(img *image.NRGBA64, x, y int, r *rand.Rand) {
+		img.SetNRGBA64(x, y, color.NRGBA64{uint16(r.Intn(2)) * 0xffff, 0, uint16(r.Intn(2)) * 0xffff, 0xffff})
+	})
+	data := encodePNG(t, img)
+	out, err := OptimizePNG(data, PNGOptimizationMax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) >= len(data)/2 {
+		t.Errorf("%d octets après optimisation (%d avant)", len(out), len(data))
+	}
+	if decoded, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Fatal(err)
+	} else if _, ok := decoded.(*image.Paletted); !ok {
+		t.Errorf("image %T au lieu d'une palette", decoded)
+	}
+}
+
+func TestOptimizePNGKeepsDensity(t *testing.T) {
+	c, err := Render(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for level := PNGOptimizationFast; level <= PNGOptimizationMax; level++ {
+		var buf bytes.Buffer
+		if err := EncodeCanvas(&buf, c, "png", Options{Height: 100, DPI: 300, PNGOptimization: level}); err != nil {
+			t.Fatal(err)
+		}
+		phys := pngChunk(buf.Bytes(), "pHYs")
+		if phys == nil {
+			t.Fatalf("niveau %d : bloc pHYs manquant", level)
+		}
+		// 300 dpi = 11811 pixels par mètre
+		if !bytes.Equal(phys[8:17], []byte{0, 0, 0x2e, 0x23, 0, 0, 0x2e, 0x23, 1}) {
+			t.Errorf("niveau %d : bloc pHYs %x", level, phys)
+		}
+		if n := bytes.Count(buf.Bytes(), []byte("pHYs")); n != 1 {
+			t.Errorf("niveau %d : %d blocs pHYs", level, n)
+		}
+	}
+}
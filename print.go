@@ -0,0 +1,392 @@
+package marianne
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// PrintColor est une couleur d'impression : sa composition en quadrichromie (CMJN)
+// et éventuellement le nom de la teinte d'accompagnement (ton direct Pantone)
+type PrintColor struct {
+	// les composantes cyan, magenta, jaune et noir, entre 0 et 1
+	C, M, Y, K float64
+	// le nom du ton direct (par exemple "PANTONE 2728 C"), vide pour la quadrichromie
+	Spot string
+}
+
+// ColorTable associe une couleur d'impression à chaque couleur RVB du logo
+type ColorTable map[color.RGBA]PrintColor
+
+// DefaultColorTable retourne la table des couleurs d'impression de la charte
+// (bleu France, rouge Marianne, le gris de la Marianne, le noir et le blanc)
+func DefaultColorTable() ColorTable {
+	return ColorTable{
+		BleuFrance:   {C: 1, M: 0.7, Spot: "PANTONE 2728 C"}, // bleu France
+		logoColor[1]: {K: 0.5},                               // gris
+		logoColor[2]: {M: 1, Y: 0.9, Spot: "PANTONE 485 C"},  // rouge Marianne
+		canvas.Black: {K: 1},
+		canvas.White: {},
+	}
+}
+
+// la couleur d'impression de c : celle de la table, sinon une conversion simple de RVB en CMJN
+func (t ColorTable) lookup(c color.RGBA) PrintColor {
+	if pc, ok := t[c]; ok {
+		return pc
+	}
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	k := 1 - math.Max(r, math.Max(g, b))
+	if k == 1 {
+		return PrintColor{K: 1}
+	}
+	return PrintColor{C: (1 - r - k) / (1 - k), M: (1 - g - k) / (1 - k), Y: (1 - b - k) / (1 - k), K: k}
+}
+
+// un chemin rempli avec une couleur (déjà transformé dans les coordonnées de la page)
+type printLayer struct {
+	path *canvas.Path
+	col  color.RGBA
+}
+
+// printRenderer est un canvas.Renderer qui garde les chemins du logo afin de les
+// écrire ensuite en PDF ou EPS avec les couleurs d'impression
+type printRenderer struct {
+	width, height float64
+	layers        []printLayer
+}
+
+// Size retourne la taille du logo
+func (r *printRenderer) Size() (float64, float64) {
+	return r.width, r.height
+}
+
+// RenderPath garde le chemin et sa couleur de remplissage
+func (r *printRenderer) RenderPath(path *canvas.Path, style canvas.Style, m canvas.Matrix) {
+	if style.FillColor.A == 0 {
+		return
+	}
+	r.layers = append(r.layers, printLayer{path.Transform(m), style.FillColor})
+}
+
+// RenderText dessine le texte sous forme de chemins (le logo n'en contient pas)
+func (r *printRenderer) RenderText(text *canvas.Text, m canvas.Matrix) {
+	canvas.RenderTextAsPath(r, text, m)
+}
+
+// RenderImage ne fait rien (le logo ne contient pas d'image)
+func (r *printRenderer) RenderImage(img image.Image, m canvas.Matrix) {}
+
+// les tons directs utilisés (triés par nom) et leur composition en CMJN
+func (r *printRenderer) spots(t ColorTable, spot bool) (names []string, cmyk map[string]PrintColor) {
+	cmyk = map[string]PrintColor{}
+	if !spot {
+		return nil, cmyk
+	}
+	for _, l := range r.layers {
+		if pc := t.lookup(l.col); pc.Spot != "" {
+			if _, ok := cmyk[pc.Spot]; !ok {
+				names = append(names, pc.Spot)
+			}
+			cmyk[pc.Spot] = pc
+		}
+	}
+	sort.Strings(names)
+	return names, cmyk
+}
+
+// un nombre (arrondi) pour PDF et PostScript
+func num(f float64) string {
+	s := fmt.Sprintf("%.4f", f)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+// le nom PDF d'un ton direct (les caractères spéciaux sont codés en #xx)
+func pdfName(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for _, c := range []byte(s) {
+		if c <= ' ' || c >= 0x7f || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// une chaîne PostScript (ou PDF) entre parenthèses
+func psString(s string) string {
+	return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s) + ")"
+}
+
+// le facteur de conversion des unités du canevas en points : writePDF et writePrintEPS
+// comptent, comme pdf.Writer de tdewolff/canvas, une unité du canevas pour 1 mm, et les pages
+// à taille réelle sont d'abord ramenées au mm (voir Page.inMillimeters). L'EPS en RVB
+// (eps.Writer) garde sa taille d'origine, une unité pour 1 pt.
+const ptPerUnit = 72 / 25.4
+
+// l'échelle des pages à taille réelle (papier à en-tête, carte de visite), dessinées en 1/100 mm
+const pageScale = 0.01
+
+// viewRenderer dessine avec le Renderer en appliquant la transformation view à tous les chemins
+type viewRenderer struct {
+	canvas.Renderer
+	view canvas.Matrix
+}
+
+// View retourne la transformation appliquée par Canvas.Render
+func (r viewRenderer) View() canvas.Matrix {
+	return r.view
+}
+
+// scaleCanvas retourne une copie du canevas c agrandie k fois
+func scaleCanvas(c *canvas.Canvas, k float64) *canvas.Canvas {
+	s := canvas.New(c.W*k, c.H*k)
+	c.Render(viewRenderer{s, canvas.Identity.Scale(k, k)})
+	return s
+}
+
+// inMillimeters retourne la page p (dessinée en 1/100 mm) avec une unité par mm
+func (p Page) inMillimeters() Page {
+	scale := func(r canvas.Rect) canvas.Rect {
+		return canvas.Rect{X: r.X * pageScale, Y: r.Y * pageScale, W: r.W * pageScale, H: r.H * pageScale}
+	}
+	return Page{Canvas: scaleCanvas(p.Canvas, pageScale), Trim: scale(p.Trim), Bleed: scale(p.Bleed)}
+}
+
+// pdfFile aide à écrire un fichier PDF objet par objet (en retenant les positions pour la table xref)
+type pdfFile struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// ajoute l'objet numéro len(offsets)+1 et retourne son numéro
+func (f *pdfFile) object(format string, a ...interface{}) int {
+	f.offsets = append(f.offsets, f.buf.Len())
+	n := len(f.offsets)
+	fmt.Fprintf(&f.buf, "%d 0 obj\n", n)
+	fmt.Fprintf(&f.buf, format, a...)
+	f.buf.WriteString("\nendobj\n")
+	return n
+}
+
+// ajoute un flux (compressé ou non) avec les entrées supplémentaires dict
+func (f *pdfFile) stream(dict string, data []byte, compress bool) int {
+	if compress {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(data)
+		zw.Close()
+		data = z.Bytes()
+		dict += " /Filter /FlateDecode"
+	}
+	return f.object("<< %s /Length %d >>\nstream\n%s\nendstream", strings.TrimSpace(dict), len(data), data)
+}
+
+// réserve un numéro d'objet (écrit plus tard avec set)
+func (f *pdfFile) reserve() int {
+	f.offsets = append(f.offsets, -1)
+	return len(f.offsets)
+}
+
+// écrit l'objet n réservé avec reserve
+func (f *pdfFile) set(n int, format string, a ...interface{}) {
+	f.offsets[n-1] = f.buf.Len()
+	fmt.Fprintf(&f.buf, "%d 0 obj\n", n)
+	fmt.Fprintf(&f.buf, format, a...)
+	f.buf.WriteString("\nendobj\n")
+}
+
+// écrit la table xref et le trailer puis copie le tout dans w
+func (f *pdfFile) close(w io.Writer, trailer string) error {
+	xref := f.buf.Len()
+	fmt.Fprintf(&f.buf, "xref\n0 %d\n0000000000 65535 f \n", len(f.offsets)+1)
+	for _, o := range f.offsets {
+		fmt.Fprintf(&f.buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&f.buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", len(f.offsets)+1, trailer, xref)
+	_, err := f.buf.WriteTo(w)
+	return err
+}
+
+// Page est une page d'un document à imprimer, dessinée en centièmes de mm : Trim est le
+// format fini (TrimBox) et Bleed la zone de fond perdu (BleedBox), vides si la page
+// n'est pas coupée après impression
+type Page struct {
+	Canvas      *canvas.Canvas
+	Trim, Bleed canvas.Rect
+}
+
+// la boîte PDF du rectangle r (en points), ou de toute la page si r est vide
+func (p Page) box(r canvas.Rect) string {
+	if r.W == 0 || r.H == 0 {
+		r = canvas.Rect{X: 0, Y: 0, W: p.Canvas.W, H: p.Canvas.H}
+	}
+	return fmt.Sprintf("[%s %s %s %s]", num(r.X*ptPerUnit), num(r.Y*ptPerUnit), num((r.X+r.W)*ptPerUnit), num((r.Y+r.H)*ptPerUnit))
+}
+
+// writePDF écrit les pages en PDF, en RVB ou, si opts.CMYK, en CMJN (DeviceCMYK) avec
+// des tons directs (Separation) si opts.Spot, et conforme à la norme opts.PDFStandard
+func writePDF(w io.Writer, pages []Page, opts Options) error {
+	if opts.PDFStandard == PDFX4 {
+		// le PDF/X-4 n'accepte que les couleurs de l'intention de sortie
+		opts.CMYK = true
+	}
+	profile, err := opts.outputProfile()
+	if err != nil {
+		return err
+	}
+	// les chemins de toutes les pages (les tons directs sont communs aux pages)
+	all := &printRenderer{}
+	var renderers []*printRenderer
+	for _, p := range pages {
+		r := &printRenderer{width: p.Canvas.W, height: p.Canvas.H}
+		p.Canvas.Render(r)
+		renderers = append(renderers, r)
+		all.layers = append(all.layers, r.layers...)
+	}
+	t := opts.colorTable()
+	names, spots := all.spots(t, opts.CMYK && opts.Spot)
+
+	f := &pdfFile{}
+	f.buf.WriteString("%PDF-1.6\n%\xe2\xe3\xcf\xd3\n")
+	catalog := f.reserve()
+	pagesObj := f.reserve()
+	// les espaces de couleurs des tons directs
+	var cs strings.Builder
+	for i, n := range names {
+		pc := spots[n]
+		fmt.Fprintf(&cs, " /CS%d [/Separation %s /DeviceCMYK << /FunctionType 2 /Domain [0 1] /C0 [0 0 0 0] /C1 [%s %s %s %s] /N 1 >>]",
+			i, pdfName(n), num(pc.C), num(pc.M), num(pc.Y), num(pc.K))
+	}
+	resources := "<< >>"
+	if cs.Len() > 0 {
+		resources = "<< /ColorSpace <<" + cs.String() + " >> >>"
+	}
+
+	// le contenu des pages
+	var kids []string
+	var stream bytes.Buffer
+	for i, r := range renderers {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "%s 0 0 %s 0 0 cm\n", num(ptPerUnit), num(ptPerUnit))
+		var current string
+		for _, l := range r.layers {
+			var op string
+			if !opts.CMYK {
+				op = fmt.Sprintf("%s %s %s rg", num(float64(l.col.R)/255), num(float64(l.col.G)/255), num(float64(l.col.B)/255))
+			} else if pc := t.lookup(l.col); pc.Spot != "" && spots[pc.Spot] == pc {
+				op = fmt.Sprintf("/CS%d cs 1 scn", sort.SearchStrings(names, pc.Spot))
+			} else {
+				op = fmt.Sprintf("%s %s %s %s k", num(pc.C), num(pc.M), num(pc.Y), num(pc.K))
+			}
+			if op != current {
+				content.WriteString(op + "\n")
+				current = op
+			}
+			content.WriteString(l.path.ToPDF() + " f\n")
+		}
+		stream.Write(content.Bytes())
+		contents := f.stream("", content.Bytes(), true)
+		p := pages[i]
+		boxes := "/MediaBox " + p.box(canvas.Rect{}) + " /TrimBox " + p.box(p.Trim)
+		if p.Bleed.W != 0 && p.Bleed.H != 0 {
+			boxes += " /BleedBox " + p.box(p.Bleed)
+		}
+		page := f.object("<< /Type /Page /Parent %d 0 R %s /Resources %s /Contents %d 0 R >>",
+			pagesObj, boxes, resources, contents)
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	f.set(pagesObj, "<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	// les métadonnées (les mêmes dans le dictionnaire Info et en XMP)
+	m := newPDFMeta(opts, stream.Bytes())
+	metadata := f.stream("/Type /Metadata /Subtype /XML", m.xmp(opts.PDFStandard), false)
+	info := f.object("%s", m.info())
+	cat := fmt.Sprintf("/Type /Catalog /Pages %d 0 R /Metadata %d 0 R", pagesObj, metadata)
+	if opts.PDFStandard != PDFStandardNone {
+		// l'intention de sortie avec son profil ICC
+		n := 3
+		if opts.CMYK {
+			n = 4
+		}
+		icc := f.stream(fmt.Sprintf("/N %d", n), profile, true)
+		intent := f.object("<< /Type /OutputIntent /S /%s /OutputConditionIdentifier %s /OutputCondition %s /Info %s /RegistryName (http://www.color.org) /DestOutputProfile %d 0 R >>",
+			opts.PDFStandard.intentSubtype(), psString(opts.outputCondition()), psString(opts.outputCondition()), psString(opts.outputCondition()), icc)
+		cat += fmt.Sprintf(" /OutputIntents [%d 0 R]", intent)
+	}
+	f.set(catalog, "<< %s >>", cat)
+	return f.close(w, fmt.Sprintf("/Root %d 0 R /Info %d 0 R /ID [<%x> <%x>]", catalog, info, m.id, m.id))
+}
+
+// writePrintEPS écrit le logo c en EPS avec des couleurs CMJN (setcmykcolor) et,
+// si opts.Spot, des tons directs (Separation) pour les couleurs qui en ont un
+func writePrintEPS(w io.Writer, c *canvas.Canvas, opts Options) error {
+	r := &printRenderer{width: c.W, height: c.H}
+	c.Render(r)
+	t := opts.colorTable()
+	names, spots := r.spots(t, opts.Spot)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%%!PS-Adobe-3.0 EPSF-3.0\n%%%%BoundingBox: 0 0 %d %d\n", int(math.Ceil(c.W*ptPerUnit)), int(math.Ceil(c.H*ptPerUnit)))
+	fmt.Fprintf(&b, "%%%%HiResBoundingBox: 0 0 %s %s\n", num(c.W*ptPerUnit), num(c.H*ptPerUnit))
+	fmt.Fprintf(&b, "%%%%Title: %s\n%%%%Creator: %s\n", psString(opts.Title()), psString(opts.Creator))
+	if len(names) > 0 {
+		// les commentaires DSC des tons directs
+		var l []string
+		for _, n := range names {
+			l = append(l, psString(n))
+		}
+		fmt.Fprintf(&b, "%%%%DocumentCustomColors: %s\n", strings.Join(l, " "))
+		for i, n := range names {
+			pc := spots[n]
+			prefix := "%%+"
+			if i == 0 {
+				prefix = "%%CMYKCustomColor:"
+			}
+			fmt.Fprintf(&b, "%s %s %s %s %s %s\n", prefix, num(pc.C), num(pc.M), num(pc.Y), num(pc.K), psString(n))
+		}
+	}
+	b.WriteString("%%EndComments\n")
+	fmt.Fprintf(&b, "%s %s scale\n", num(ptPerUnit), num(ptPerUnit))
+	var current string
+	for _, l := range r.layers {
+		pc := t.lookup(l.col)
+		var op string
+		if _, ok := spots[pc.Spot]; ok && pc.Spot != "" {
+			op = fmt.Sprintf("[/Separation %s /DeviceCMYK {dup %s mul exch dup %s mul exch dup %s mul exch %s mul}] setcolorspace 1 setcolor",
+				psString(pc.Spot), num(pc.C), num(pc.M), num(pc.Y), num(pc.K))
+		} else {
+			op = fmt.Sprintf("%s %s %s %s setcmykcolor", num(pc.C), num(pc.M), num(pc.Y), num(pc.K))
+		}
+		if op != current {
+			b.WriteString(op + "\n")
+			current = op
+		}
+		b.WriteString("newpath " + l.path.ToPS() + " fill\n")
+	}
+	b.WriteString("showpage\n%%EOF\n")
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// la table des couleurs d'impression (celle de la charte si elle n'est pas précisée)
+func (opts Options) colorTable() ColorTable {
+	if opts.ColorTable == nil {
+		return DefaultColorTable()
+	}
+	return opts.ColorTable
+}
@@ -0,0 +1,169 @@
+package marianne
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// pdfOf écrit le logo de opts en PDF
+func pdfOf(t *testing.T, opts Options) []byte {
+	t.Helper()
+	c, err := Render(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := EncodeCanvas(&buf, c, "pdf", opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// mediaBoxes retourne les MediaBox du PDF data
+func mediaBoxes(data []byte) []string {
+	var boxes []string
+	for _, m := range regexp.MustCompile(`/MediaBox \[([^\]]*)\]`).FindAllSubmatch(data, -1) {
+		boxes = append(boxes, string(m[1]))
+	}
+	return boxes
+}
+
+// checkXref vérifie que chaque entrée de la table xref du PDF data pointe sur son objet
+func checkXref(t *testing.T, data []byte) {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("startxref manquant")
+	}
+	start, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[start:], []byte("xref")) {
+		t.Fatalf("startxref %d ne pointe pas sur la table xref", start)
+	}
+	xref := regexp.MustCompile(`^xref\s+0 (\d+)\s+`).FindSubmatch(data[start:])
+	if xref == nil {
+		t.Fatal("en-tête de la table xref invalide")
+	}
+	n, _ := strconv.Atoi(string(xref[1]))
+	entries := regexp.MustCompile(`(\d{10}) (\d{5}) ([nf])`).FindAllSubmatch(data[start:], n)
+	if len(entries) != n {
+		t.Fatalf("%d entrées dans la table xref au lieu de %d", len(entries), n)
+	}
+	for i, e := range entries[1:] {
+		offset, _ := strconv.Atoi(string(e[1]))
+		want := strconv.Itoa(i+1) + " 0 obj"
+		if !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Errorf("l'entrée xref %d (position %d) ne pointe pas sur %q", i+1, offset, want)
+		}
+	}
+}
+
+func TestPDFSameSize(t *testing.T) {
+	rgb := mediaBoxes(pdfOf(t, Options{}))
+	if len(rgb) != 1 {
+		t.Fatalf("MediaBox du PDF RVB : %v", rgb)
+	}
+	for name, opts := range map[string]Options{
+		"cmjn":      {CMYK: true},
+		"pantone":   {CMYK: true, Spot: true},
+		"pdfa-2b":   {PDFStandard: PDFA2b},
+		"pdfx-4":    {PDFStandard: PDFX4, ICCProfile: cmykProfile()},
+		"pdfa-cmjn": {PDFStandard: PDFA2b, CMYK: true},
+	} {
+		data := pdfOf(t, opts)
+		checkXref(t, data)
+		boxes := mediaBoxes(data)
+		if len(boxes) != 1 {
+			t.Fatalf("%s : MediaBox %v", name, boxes)
+		}
+		var x1, y1, w1, h1, x2, y2, w2, h2 float64
+		if _, err := fmt.Sscan(rgb[0], &x1, &y1, &w1, &h1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fmt.Sscan(boxes[0], &x2, &y2, &w2, &h2); err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(w1-w2) > 0.01 || math.Abs(h1-h2) > 0.01 {
+			t.Errorf("%s : MediaBox [%s] au lieu de [%s] (comme en RVB)", name, boxes[0], rgb[0])
+		}
+	}
+}
+
+func TestPDFXNeedsProfile(t *testing.T) {
+	c, err := Render(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = EncodeCanvas(&buf, c, "pdf", Options{PDFStandard: PDFX4})
+	if KindOf(err) != ParameterError {
+		t.Errorf("PDF/X-4 sans profil ICC : erreur %v au lieu d'une erreur de paramètre", err)
+	}
+	// l'intention de sortie nomme la condition d'impression du profil donné
+	data := pdfOf(t, Options{PDFStandard: PDFX4, ICCProfile: cmykProfile()})
+	if bytes.Contains(data, []byte("marianne CMJN")) {
+		t.Error("le PDF/X-4 ne doit pas nommer une condition d'impression inventée")
+	}
+}
+
+func TestPDFPagesRealSize(t *testing.T) {
+	c, err := RenderLetterhead(Options{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := EncodePage(&buf, c, "pdf", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	checkXref(t, buf.Bytes())
+	// A4 : 210 x 297 mm
+	if boxes := mediaBoxes(buf.Bytes()); len(boxes) != 1 || boxes[0] != "0 0 595.2756 841.8898" {
+		t.Errorf("MediaBox du papier à en-tête : %v", boxes)
+	}
+
+	pages, err := RenderBusinessCard(Options{}, Agent{Name: "Camille Durand"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := EncodePages(&buf, pages, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	checkXref(t, buf.Bytes())
+	// 85 x 55 mm, avec une marge de 10 mm tout autour
+	if m := regexp.MustCompile(`/TrimBox \[([^\]]*)\]`).FindSubmatch(buf.Bytes()); m == nil || string(m[1]) != "28.3465 28.3465 269.2913 184.252" {
+		t.Errorf("TrimBox de la carte de visite : %q", m)
+	}
+}
+
+func TestEPSSize(t *testing.T) {
+	c, err := Render(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`%%BoundingBox: 0 0 ([\d.]+) ([\d.]+)`)
+	var size [2][2]float64
+	for i, opts := range []Options{{}, {CMYK: true}} {
+		var buf bytes.Buffer
+		if err := EncodeCanvas(&buf, c, "eps", opts); err != nil {
+			t.Fatal(err)
+		}
+		m := re.FindSubmatch(buf.Bytes())
+		if m == nil {
+			t.Fatal("BoundingBox manquante")
+		}
+		size[i][0], _ = strconv.ParseFloat(string(m[1]), 64)
+		size[i][1], _ = strconv.ParseFloat(string(m[2]), 64)
+	}
+	// l'EPS en RVB garde sa taille d'origine (une unité pour 1 pt)
+	if math.Abs(size[0][0]-c.W) > 1e-3 || math.Abs(size[0][1]-c.H) > 1e-3 {
+		t.Errorf("BoundingBox en RVB %v au lieu de [%g %g]", size[0], c.W, c.H)
+	}
+	// l'EPS en CMJN a la taille des PDF (une unité pour 1 mm), arrondie au point supérieur
+	if math.Abs(size[1][0]-c.W*ptPerUnit) > 1 || math.Abs(size[1][1]-c.H*ptPerUnit) > 1 {
+		t.Errorf("BoundingBox en CMJN %v au lieu de [%g %g]", size[1], c.W*ptPerUnit, c.H*ptPerUnit)
+	}
+}
@@ -0,0 +1,250 @@
+package marianne
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// une couleur de l'image et son nombre de pixels (pour la coupe médiane)
+type colorCount struct {
+	c     [3]uint8
+	count int
+}
+
+// une boîte de la coupe médiane : des couleurs voisines qui donneront une couleur de la palette
+type colorBox []colorCount
+
+// pixels retourne le nombre de pixels des couleurs de la boîte
+func (b colorBox) pixels() int {
+	n := 0
+	for _, c := range b {
+		n += c.count
+	}
+	return n
+}
+
+// widest retourne la composante (0, 1 ou 2 pour R, V, B) la plus étendue de la boîte et son étendue
+func (b colorBox) widest() (int, int) {
+	axis, width := 0, 0
+	for i := 0; i < 3; i++ {
+		lo, hi := 255, 0
+		for _, c := range b {
+			if int(c.c[i]) < lo {
+				lo = int(c.c[i])
+			}
+			if int(c.c[i]) > hi {
+				hi = int(c.c[i])
+			}
+		}
+		if hi-lo > width {
+			axis, width = i, hi-lo
+		}
+	}
+	return axis, width
+}
+
+// mean retourne la moyenne des couleurs de la boîte (pondérée par le nombre de pixels)
+func (b colorBox) mean() color.RGBA {
+	var sum [3]int
+	n := b.pixels()
+	for _, c := range b {
+		for i := 0; i < 3; i++ {
+			sum[i] += int(c.c[i]) * c.count
+		}
+	}
+	return color.RGBA{uint8((sum[0] + n/2) / n), uint8((sum[1] + n/2) / n), uint8((sum[2] + n/2) / n), 0xff}
+}
+
+// split coupe la boîte en deux à la médiane (en pixels) de sa composante la plus étendue
+func (b colorBox) split() (colorBox, colorBox) {
+	axis, _ := b.widest()
+	sort.Slice(b, func(i, j int) bool { return b[i].c[axis] < b[j].c[axis] })
+	half, n := b.pixels()/2, 0
+	for i := range b[:len(b)-1] {
+		if n += b[i].count; n >= half {
+			return b[:i+1], b[i+1:]
+		}
+	}
+	return b[:len(b)-1], b[len(b)-1:]
+}
+
+// AdaptivePalette retourne une palette de n couleurs (entre 1 et 256) adaptée à l'image img :
+// les couleurs de fixed présentes dans l'image sont gardées telles quelles, et les autres
+// couleurs sont obtenues par coupe médiane des pixels restants (le plus souvent les nuances
+// de l'anticrénelage). Les pixels opaques à moins de 25% sont ignorés, les autres sont
+// considérés comme opaques (voir ToTransparentIndexedImg).
+func AdaptivePalette(img image.Image, n int, fixed color.Palette) color.Palette {
+	if n < 1 {
+		n = 1
+	} else if n > 256 {
+		n = 256
+	}
+	// l'histogramme des couleurs
+	hist := map[[3]uint8]int{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 0x40 {
+				continue
+			}
+			hist[[3]uint8{c.R, c.G, c.B}]++
+		}
+	}
+	// les couleurs imposées présentes dans l'image
+	var p color.Palette
+	for _, f := range fixed {
+		c := color.RGBAModel.Convert(f).(color.RGBA)
+		k := [3]uint8{c.R, c.G, c.B}
+		if _, ok := hist[k]; ok && len(p) < n {
+			p = append(p, color.RGBA{c.R, c.G, c.B, 0xff})
+			delete(hist, k)
+		}
+	}
+	if len(hist) == 0 || len(p) == n {
+		if len(p) == 0 {
+			p = append(p, color.RGBA{0, 0, 0, 0xff})
+		}
+		return p
+	}
+	// la coupe médiane des autres couleurs (triées pour que la palette ne dépende pas de l'ordre de la map)
+	all := make(colorBox, 0, len(hist))
+	for c, count := range hist {
+		all = append(all, colorCount{c, count})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].c, all[j].c
+		return a[0] < b[0] || (a[0] == b[0] && (a[1] < b[1] || (a[1] == b[1] && a[2] < b[2])))
+	})
+	boxes := []colorBox{all}
+	for len(p)+len(boxes) < n {
+		// la boîte à couper : celle qui a le plus de pixels fois son étendue
+		best, score := -1, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if _, w := box.widest(); w*box.pixels() > score {
+				best, score = i, w*box.pixels()
+			}
+		}
+		if best < 0 {
+			break
+		}
+		b1, b2 := boxes[best].split()
+		boxes[best] = b1
+		boxes = append(boxes, b2)
+	}
+	for _, box := range boxes {
+		p = append(p, box.mean())
+	}
+	return p
+}
+
+// ToDitheredImg transforme une image RGBA en image avec les couleurs de la palette p
+// en diffusant l'erreur avec l'algorithme de Floyd-Steinberg (voir ToIndexedImg)
+func ToDitheredImg(rgba image.Image, p color.Palette) image.Image {
+	rect := image.Rect(0, 0, rgba.Bounds().Dx(), rgba.Bounds().Dy())
+	img := image.NewPaletted(rect, p)
+	draw.FloydSteinberg.Draw(img, rect, rgba, rgba.Bounds().Min)
+	return img
+}
+
+// fixedColors retourne les couleurs pleines du logo (le fond, le texte, la Marianne et
+// opts.ExtraColors vues sur le fond) que les palettes adaptées gardent telles quelles
+func (opts Options) fixedColors() color.Palette {
+	c := opts.colors()
+	p := color.Palette{c.background, c.text, c.marianne[0], c.marianne[1], c.marianne[2]}
+	for _, e := range opts.ExtraColors {
+		if e.A != 0xff {
+			e = over(e, c.background)
+		}
+		p = append(p, e)
+	}
+	return p
+}
+
+// IndexImage réduit l'image img (sans transparence) aux couleurs de opts pour les PNG, GIF
+// et WebP sans perte : la palette du logo (voir Palette) ou, avec opts.Colors, une palette
+// de opts.Colors couleurs adaptée à l'image (voir AdaptivePalette), avec le tramage de
+// Floyd-Steinberg si opts.Dither
+func IndexImage(img image.Image, opts Options) image.Image {
+	p := opts.Palette()
+	if opts.Colors > 0 {
+		p = AdaptivePalette(img, opts.Colors, opts.fixedColors())
+	}
+	if opts.Dither {
+		return ToDitheredImg(img, p)
+	}
+	return ToIndexedImg(img, p)
+}
+
+// ToDitheredTransparentImg transforme une image RGBA avec transparence en image avec les
+// couleurs de la palette p (au plus 255) plus une couleur transparente (l'index 0), comme
+// ToTransparentIndexedImg mais en diffusant l'erreur avec l'algorithme de Floyd-Steinberg.
+// L'erreur n'est diffusée ni vers les pixels transparents ni à partir d'eux : sinon elle
+// traverserait le fond et ferait apparaître des points le long des bords lissés.
+func ToDitheredTransparentImg(rgba image.Image, p color.Palette) image.Image {
+	if len(p) > 255 {
+		p = p[:255]
+	}
+	b := rgba.Bounds()
+	w, h := b.Dx(), b.Dy()
+	img := image.NewPaletted(image.Rect(0, 0, w, h), append(color.Palette{color.NRGBA{}}, p...))
+	// les couleurs des pixels, et ceux qui deviennent transparents (l'index 0)
+	pix := make([]color.NRGBA, w*h)
+	transparent := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(rgba.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			pix[y*w+x], transparent[y*w+x] = c, c.A < 0x40
+		}
+	}
+	// l'erreur accumulée (en seizièmes) de chaque pixel
+	errs := make([][3]int32, w*h)
+	spread := func(x, y int, d [3]int32, k int32) {
+		if x < 0 || x >= w || y >= h || transparent[y*w+x] {
+			return
+		}
+		for i := range d {
+			errs[y*w+x][i] += d[i] * k
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if transparent[y*w+x] {
+				continue
+			}
+			c, e := pix[y*w+x], errs[y*w+x]
+			v := [3]int32{int32(c.R), int32(c.G), int32(c.B)}
+			for i := range v {
+				v[i] = clamp255(v[i] + (e[i]+8)>>4)
+			}
+			k := p.Index(color.RGBA{uint8(v[0]), uint8(v[1]), uint8(v[2]), 0xff})
+			img.Pix[y*img.Stride+x] = uint8(1 + k)
+			r, g, bl, _ := p[k].RGBA()
+			d := [3]int32{v[0] - int32(r>>8), v[1] - int32(g>>8), v[2] - int32(bl>>8)}
+			spread(x+1, y, d, 7)
+			spread(x-1, y+1, d, 3)
+			spread(x, y+1, d, 5)
+			spread(x+1, y+1, d, 1)
+		}
+	}
+	return img
+}
+
+// transparentIndexImg réduit l'image img avec transparence aux couleurs de opts (au plus 255)
+// plus une couleur transparente (pour les GIF, voir ToTransparentIndexedImg), avec le
+// tramage de Floyd-Steinberg si opts.Dither
+func transparentIndexImg(img image.Image, opts Options) image.Image {
+	p := opts.Palette()
+	if opts.Colors > 0 {
+		p = AdaptivePalette(img, opts.Colors-1, opts.fixedColors())
+	}
+	if opts.Dither {
+		return ToDitheredTransparentImg(img, p)
+	}
+	return ToTransparentIndexedImg(img, p)
+}
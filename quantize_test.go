@@ -0,0 +1,117 @@
+package marianne
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// transparentLogo retourne le logo sur fond transparent
+func transparentLogo(t *testing.T, opts Options) image.Image {
+	t.Helper()
+	opts.Transparent = true
+	c, err := Render(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return CanvasToImage(c, 0, 150, opts)
+}
+
+func TestTransparentIndexPaletteSize(t *testing.T) {
+	// 300 couleurs ajoutées : la palette (avec la couleur transparente) ne dépasse pas 256
+	var extra []color.RGBA
+	for i := 0; i < 300; i++ {
+		extra = append(extra, color.RGBA{uint8(i), uint8(i / 2), 0x80, 0xff})
+	}
+	opts := Options{ExtraColors: extra}
+	for _, dither := range []bool{false, true} {
+		opts.Dither = dither
+		img := transparentIndexImg(transparentLogo(t, opts), opts).(*image.Paletted)
+		if len(img.Palette) > 256 {
+			t.Errorf("tramage %v : %d couleurs dans la palette", dither, len(img.Palette))
+		}
+	}
+	opts = Options{Colors: 256}
+	img := transparentIndexImg(transparentLogo(t, opts), opts).(*image.Paletted)
+	if len(img.Palette) > 256 {
+		t.Errorf("%d couleurs dans la palette adaptée", len(img.Palette))
+	}
+}
+
+func TestTransparentIndexDither(t *testing.T) {
+	opts := Options{Colors: 4}
+	src := transparentLogo(t, opts)
+	plain := transparentIndexImg(src, opts).(*image.Paletted)
+	opts.Dither = true
+	dithered := transparentIndexImg(src, opts).(*image.Paletted)
+
+	differ := false
+	for i := range plain.Pix {
+		// la transparence est la même avec ou sans tramage
+		if (plain.Pix[i] == 0) != (dithered.Pix[i] == 0) {
+			t.Fatalf("le pixel %d n'a pas la même transparence avec le tramage", i)
+		}
+		differ = differ || plain.Pix[i] != dithered.Pix[i]
+	}
+	if !differ {
+		t.Error("le tramage n'est pas appliqué aux images transparentes")
+	}
+}
+
+func TestDitheredTransparentEdges(t *testing.T) {
+	// un dégradé opaque entouré d'un fond transparent (de couleur rouge, invisible)
+	const m = 5
+	block := image.NewNRGBA(image.Rect(0, 0, 20, 12))
+	framed := image.NewNRGBA(image.Rect(0, 0, 20+2*m, 12+2*m))
+	for i := 0; i < len(framed.Pix); i += 4 {
+		framed.Pix[i] = 0xff
+	}
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 20; x++ {
+			c := color.NRGBA{uint8(x * 12), uint8(y * 20), 0x80, 0xff}
+			block.SetNRGBA(x, y, c)
+			framed.SetNRGBA(x+m, y+m, c)
+		}
+	}
+	p := color.Palette{color.Black, color.White, color.RGBA{0x80, 0x80, 0x80, 0xff}}
+	alone := ToDitheredTransparentImg(block, p).(*image.Paletted)
+	inFrame := ToDitheredTransparentImg(framed, p).(*image.Paletted)
+	// le fond transparent ne reçoit ni ne transmet d'erreur : le tramage du dégradé est le
+	// même que sans le fond, et le fond reste transparent
+	for y := 0; y < 12+2*m; y++ {
+		for x := 0; x < 20+2*m; x++ {
+			got := inFrame.ColorIndexAt(x, y)
+			want := uint8(0)
+			if x >= m && x < 20+m && y >= m && y < 12+m {
+				want = alone.ColorIndexAt(x-m, y-m)
+			}
+			if got != want {
+				t.Fatalf("le pixel (%d, %d) a l'index %d au lieu de %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestTransparentPNGColors(t *testing.T) {
+	opts := Options{Colors: 4, Transparent: true}
+	var buf bytes.Buffer
+	if err := EncodeImage(&buf, transparentLogo(t, opts), "png", opts); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := img.(*image.Paletted)
+	if !ok {
+		t.Fatalf("image %T au lieu d'une palette", img)
+	}
+	if len(p.Palette) > 4 {
+		t.Errorf("%d couleurs au lieu de 4 au plus", len(p.Palette))
+	}
+	if _, _, _, a := p.Palette[0].RGBA(); a != 0 {
+		t.Errorf("la première couleur %v n'est pas transparente", p.Palette[0])
+	}
+}
@@ -0,0 +1,113 @@
+package marianne
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+)
+
+// CanvasToRGBAImg transforme les chemins du canevas en image RGB de hauteur oh pixels
+// (voir CanvasToImage pour choisir le suréchantillonnage et le filtre)
+func CanvasToRGBAImg(c *canvas.Canvas, oh uint) image.Image {
+	return CanvasToImage(c, 0, oh, Options{})
+}
+
+// CanvasToRGBAImgWidth transforme les chemins du canevas en image RGB de largeur ow
+// pixels (la hauteur est calculée à partir des proportions du canevas)
+func CanvasToRGBAImgWidth(c *canvas.Canvas, ow uint) image.Image {
+	return CanvasToImage(c, ow, 0, Options{})
+}
+
+// HeightForWidth retourne la hauteur en pixels de l'image du canevas c de largeur ow pixels
+func HeightForWidth(c *canvas.Canvas, ow uint) uint {
+	if oh := uint(c.H*float64(ow)/c.W + 0.5); oh > 0 {
+		return oh
+	}
+	return 1
+}
+
+// WidthForHeight retourne la largeur en pixels de l'image du canevas c de hauteur oh pixels
+func WidthForHeight(c *canvas.Canvas, oh uint) uint {
+	if ow := uint(c.W*float64(oh)/c.H + 0.5); ow > 0 {
+		return ow
+	}
+	return 1
+}
+
+// MariannePalette16 contient les 16 couleurs du logo pour PNG et GIF
+var MariannePalette16 = color.Palette{
+	color.RGBA{0x0c, 0x0c, 0x0c, 0xff},
+	color.RGBA{0xff, 0xff, 0xff, 0xff},
+	color.RGBA{0x79, 0x79, 0x7c, 0xff},
+	color.RGBA{0xa3, 0xa3, 0xa4, 0xff},
+	color.RGBA{0x09, 0x09, 0x94, 0xff},
+	color.RGBA{0xd7, 0xd7, 0xd9, 0xff},
+	color.RGBA{0xe1, 0x04, 0x11, 0xff},
+	color.RGBA{0xf2, 0xf2, 0xf2, 0xff},
+	color.RGBA{0xf9, 0xcb, 0xce, 0xff},
+	color.RGBA{0xc1, 0xc0, 0xc1, 0xff},
+	color.RGBA{0x47, 0x47, 0x47, 0xff},
+	color.RGBA{0xed, 0x5f, 0x70, 0xff},
+	color.RGBA{0xfb, 0xdd, 0xdf, 0xff},
+	color.RGBA{0xf0, 0xf0, 0xf9, 0xff},
+	color.RGBA{0xe7, 0xe7, 0xe7, 0xff},
+	color.RGBA{0xfa, 0xfa, 0xfa, 0xff},
+}
+
+// MariannePalette8 contient les 8 couleurs du logo pour PNG et GIF
+var MariannePalette8 = color.Palette{
+	color.NRGBA{0xff, 0xff, 0xff, 0xff}, // blanc
+	color.NRGBA{0x05, 0x05, 0x05, 0xff}, // noir
+	color.NRGBA{0x80, 0x80, 0x82, 0xff}, // gris
+	color.NRGBA{0xb3, 0xb2, 0xb3, 0xff}, // gris
+	color.NRGBA{0x00, 0x00, 0x91, 0xff}, // bleu
+	color.NRGBA{0xe1, 0x00, 0x0f, 0xff}, // rouge
+	color.NRGBA{0xdb, 0xdb, 0xdb, 0xff}, // gris
+	color.NRGBA{0xea, 0x65, 0x67, 0xff}, // rouge pale
+}
+
+// ToTransparentIndexedImg transforme une image RGBA avec transparence en image avec les
+// couleurs de logoPalette plus une couleur transparente (l'index 0) : les pixels opaques à
+// moins de 25% deviennent transparents, les autres prennent la couleur la plus proche (pour les GIF).
+// Seules les 255 premières couleurs de logoPalette sont gardées.
+func ToTransparentIndexedImg(rgba image.Image, logoPalette color.Palette) image.Image {
+	if len(logoPalette) > 255 {
+		logoPalette = logoPalette[:255]
+	}
+	b := rgba.Bounds()
+	p := append(color.Palette{color.NRGBA{}}, logoPalette...)
+	img := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), p)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(rgba.At(x, y)).(color.NRGBA)
+			if c.A < 0x40 {
+				continue // l'index 0 est transparent
+			}
+			c.A = 0xff
+			img.SetColorIndex(x-b.Min.X, y-b.Min.Y, uint8(1+logoPalette.Index(c)))
+		}
+	}
+	return img
+}
+
+// FlattenImg met l'image img (avec transparence) sur fond de couleur bg
+func FlattenImg(img image.Image, bg color.Color) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.ZP, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// ToIndexedImg transforme une image RGBA en image avec les couleurs de logoPalette
+// (voir Options.Palette pour les palettes de 8 ou 16 couleurs du logo)
+func ToIndexedImg(rgba image.Image, logoPalette color.Palette) (img image.Image) {
+	rect := image.Rect(0, 0, rgba.Bounds().Dx(), rgba.Bounds().Dy())
+	img = image.NewPaletted(rect, logoPalette)
+	dimg, _ := img.(draw.Image)
+	draw.Draw(dimg, rect, rgba, image.ZP, draw.Src)
+
+	return img
+}
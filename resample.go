@@ -0,0 +1,192 @@
+package marianne
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"github.com/nfnt/resize"     // pour pouvoir dessiner puis rétrécir le logo (pour les petites tailles)
+	"github.com/tdewolff/canvas" // la bibliothèque principale pour réaliser le logo
+	"github.com/tdewolff/canvas/rasterizer"
+)
+
+// Filter est le filtre qui réduit l'image suréchantillonnée à sa taille finale
+type Filter string
+
+// les filtres de rééchantillonnage
+const (
+	// Lanczos (par défaut) : le plus net, avec un léger halo le long des bords
+	FilterLanczos Filter = "lanczos"
+	// Mitchell-Netravali : un peu plus doux que Lanczos, sans halo
+	FilterMitchell Filter = "mitchell"
+	// la moyenne des pixels de chaque bloc : la couverture exacte, idéale avec l'alignement sur les pixels
+	FilterBox Filter = "boite"
+	// sans suréchantillonnage, le logo est dessiné directement à sa taille finale
+	FilterNone Filter = "aucun"
+)
+
+// le facteur de suréchantillonnage maximal (l'image intermédiaire a maxSupersampling² fois plus de pixels)
+const maxSupersampling = 8
+
+// ParseFilter retourne le filtre correspondant au nom (vide pour Lanczos)
+func ParseFilter(name string) (Filter, error) {
+	switch f := Filter(strings.ToLower(strings.TrimSpace(name))); f {
+	case "", FilterLanczos, "lanczos3":
+		return FilterLanczos, nil
+	case FilterMitchell, FilterBox, FilterNone:
+		return f, nil
+	case "boîte", "box":
+		return FilterBox, nil
+	case "none":
+		return FilterNone, nil
+	}
+	return "", parameterError("filtre inconnu %q (lanczos, mitchell, boite ou aucun)", name)
+}
+
+// supersampling retourne le facteur de suréchantillonnage d'une image de oh pixels de haut :
+// celui de opts, ou par défaut 2 en dessous de 700 pixels (1 sans filtre)
+func (opts Options) supersampling(oh uint) uint {
+	switch {
+	case opts.Filter == FilterNone:
+		return 1
+	case opts.Supersampling > maxSupersampling:
+		return maxSupersampling
+	case opts.Supersampling > 0:
+		return opts.Supersampling
+	case oh < 700:
+		return 2
+	}
+	return 1
+}
+
+// CanvasToImage transforme les chemins du canevas en image RGB de ow x oh pixels (si ow ou oh
+// est nul il est calculé à partir des proportions du canevas), avec le suréchantillonnage,
+// le filtre et l'alignement sur les pixels de opts
+func CanvasToImage(c *canvas.Canvas, ow, oh uint, opts Options) image.Image {
+	switch {
+	case ow == 0 && oh == 0:
+		oh = opts.normalize().Height
+		ow = WidthForHeight(c, oh)
+	case ow == 0:
+		ow = WidthForHeight(c, oh)
+	case oh == 0:
+		oh = HeightForWidth(c, ow)
+	}
+	k := opts.supersampling(oh)
+	img := image.NewRGBA(image.Rect(0, 0, int(k*ow), int(k*oh)))
+	dpmm := float64(k*oh) / c.H
+	var r canvas.Renderer = rasterizer.New(img, canvas.DPMM(dpmm))
+	if opts.Hinting {
+		r = pixelGrid{r, dpmm / float64(k)}
+	}
+	c.Render(r)
+	switch {
+	case k == 1:
+		return img
+	case opts.Filter == FilterBox:
+		return boxDownsample(img, int(k))
+	case opts.Filter == FilterMitchell:
+		return resize.Resize(ow, oh, img, resize.MitchellNetravali)
+	}
+	return resize.Resize(ow, oh, img, resize.Lanczos3)
+}
+
+// boxDownsample réduit img d'un facteur k en faisant la moyenne de chaque bloc de k x k pixels
+// (les couleurs de image.RGBA sont prémultipliées par l'alpha, la moyenne est donc exacte)
+func boxDownsample(img *image.RGBA, k int) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()/k, b.Dy()/k))
+	n := uint32(k * k)
+	for y := 0; y < out.Rect.Dy(); y++ {
+		for x := 0; x < out.Rect.Dx(); x++ {
+			var sum [4]uint32
+			for j := 0; j < k; j++ {
+				i := img.PixOffset(b.Min.X+x*k, b.Min.Y+y*k+j)
+				for ; i < img.PixOffset(b.Min.X+x*k+k, b.Min.Y+y*k+j); i += 4 {
+					sum[0] += uint32(img.Pix[i])
+					sum[1] += uint32(img.Pix[i+1])
+					sum[2] += uint32(img.Pix[i+2])
+					sum[3] += uint32(img.Pix[i+3])
+				}
+			}
+			o := out.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				out.Pix[o+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return out
+}
+
+// pixelGrid aligne les chemins sur la grille des pixels de l'image finale (dpmm pixels par
+// unité du canevas) avant de les dessiner avec le Renderer : les rectangles (comme le trait
+// séparateur) ont leurs bords sur les pixels et au moins un pixel d'épaisseur, et les autres
+// chemins ont leur origine (la ligne de base des textes) sur le bord d'un pixel
+type pixelGrid struct {
+	canvas.Renderer
+	dpmm float64
+}
+
+// RenderPath aligne le chemin p sur les pixels puis le dessine
+func (g pixelGrid) RenderPath(p *canvas.Path, style canvas.Style, m canvas.Matrix) {
+	if m[0][1] != 0 || m[1][0] != 0 {
+		// avec une rotation il n'y a rien à aligner
+		g.Renderer.RenderPath(p, style, m)
+		return
+	}
+	if isRectangle(p) {
+		r := p.Transform(m).Bounds()
+		x0, x1 := g.snap(r.X, r.X+r.W)
+		y0, y1 := g.snap(r.Y, r.Y+r.H)
+		g.Renderer.RenderPath(canvas.Rectangle(x1-x0, y1-y0), style, canvas.Identity.Translate(x0, y0))
+		return
+	}
+	y := m.Dot(canvas.Point{}).Y * g.dpmm
+	g.Renderer.RenderPath(p, style, canvas.Identity.Translate(0, (math.Round(y)-y)/g.dpmm).Mul(m))
+}
+
+// snap arrondit l'intervalle [a, b] aux bords des pixels, avec au moins un pixel de large
+func (g pixelGrid) snap(a, b float64) (float64, float64) {
+	pa, pb := math.Round(a*g.dpmm), math.Round(b*g.dpmm)
+	if pb-pa < 1 {
+		pa = math.Floor((a + b) / 2 * g.dpmm)
+		pb = pa + 1
+	}
+	return pa / g.dpmm, pb / g.dpmm
+}
+
+// isRectangle indique si le chemin p est un rectangle aux côtés horizontaux et verticaux
+// (un seul contour dont les 4 sommets sont les coins de sa boîte englobante)
+func isRectangle(p *canvas.Path) bool {
+	coords := p.Coords()
+	if n := len(coords); n == 5 && coords[4].Equals(coords[0]) {
+		coords = coords[:4]
+	}
+	if len(coords) != 4 {
+		return false
+	}
+	r := p.Bounds()
+	if r.W <= 0 || r.H <= 0 {
+		return false
+	}
+	var corners [4]bool
+	for _, c := range coords {
+		i := 0
+		switch {
+		case canvas.Equal(c.X, r.X):
+		case canvas.Equal(c.X, r.X+r.W):
+			i = 1
+		default:
+			return false
+		}
+		switch {
+		case canvas.Equal(c.Y, r.Y):
+		case canvas.Equal(c.Y, r.Y+r.H):
+			i += 2
+		default:
+			return false
+		}
+		corners[i] = true
+	}
+	return corners[0] && corners[1] && corners[2] && corners[3]
+}
@@ -0,0 +1,130 @@
+package marianne
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Agent contient les coordonnées de l'agent pour la signature mail
+type Agent struct {
+	// Le prénom et le nom.
+	Name string
+	// La fonction.
+	Title string
+	// Le numéro de téléphone.
+	Phone string
+	// L'adresse postale (le passage à la ligne se fait avec le EOL des options).
+	Address string
+	// L'adresse électronique.
+	Email string
+}
+
+// SignatureImage décrit le logo de la signature mail
+type SignatureImage struct {
+	// L'adresse de l'image : une URL http(s) ou une image intégrée (data:image/png;base64,...).
+	Src string
+	// La taille de l'image en pixels. Elle est affichée à la moitié de cette taille
+	// pour rester nette sur les écrans haute densité.
+	Width, Height int
+}
+
+// les données du modèle de la signature
+type signatureData struct {
+	Agent
+	Lines         []string // les lignes de l'adresse
+	Tel           string
+	Src           template.URL
+	Alt           string
+	Width, Height int
+	Color, Link   string
+}
+
+// la signature en tableaux (la seule mise en page comprise par Outlook) avec les styles
+// sur chaque élément (les clients mail ignorent les feuilles de style)
+var signatureHTML = template.Must(template.New("signature").Parse(`<table cellpadding="0" cellspacing="0" border="0" role="presentation" style="border-collapse:collapse;font-family:Arial,Helvetica,sans-serif;font-size:13px;line-height:18px;color:{{.Color}};">
+{{- if or .Name .Title}}
+<tr><td style="padding:0 0 10px 0;">
+{{- if .Name}}<strong style="font-size:14px;">{{.Name}}</strong>{{end}}
+{{- if and .Name .Title}}<br>{{end}}
+{{- if .Title}}{{.Title}}{{end}}</td></tr>
+{{- end}}
+<tr><td style="padding:0 0 10px 0;"><img src="{{.Src}}" width="{{.Width}}" height="{{.Height}}" alt="{{.Alt}}" style="display:block;border:0;outline:none;width:{{.Width}}px;height:{{.Height}}px;"></td></tr>
+{{- if or .Lines .Phone .Email}}
+<tr><td style="padding:0;">
+{{- range $i, $l := .Lines}}{{if $i}}<br>{{end}}{{$l}}{{end}}
+{{- if .Phone}}{{if .Lines}}<br>{{end}}Tél. : <a href="tel:{{.Tel}}" style="color:{{.Color}};text-decoration:none;">{{.Phone}}</a>{{end}}
+{{- if .Email}}{{if or .Lines .Phone}}<br>{{end}}<a href="mailto:{{.Email}}" style="color:{{.Link}};">{{.Email}}</a>{{end}}</td></tr>
+{{- end}}
+</table>
+`))
+
+// EncodeSignatureHTML écrit la signature mail HTML de l'agent a, prête à être collée dans
+// Outlook ou Thunderbird : les coordonnées et le logo img (généré avec opts)
+func EncodeSignatureHTML(w io.Writer, a Agent, img SignatureImage, opts Options) error {
+	opts = opts.normalize()
+	if img.Src == "" || img.Width <= 0 || img.Height <= 0 {
+		return parameterError("image invalide pour la signature")
+	}
+	// l'adresse est insérée telle quelle (template.URL) : seules les adresses sûres sont acceptées
+	if !safeImageURL(img.Src) {
+		return parameterError("adresse invalide pour l'image de la signature %q (http, https ou data:image/)", img.Src)
+	}
+	data := signatureData{
+		Agent:  a,
+		Lines:  textLines(a.Address, opts.EOL),
+		Tel:    strings.NewReplacer(" ", "", ".", "", "-", "", "(", "", ")", "").Replace(a.Phone),
+		Src:    template.URL(img.Src),
+		Alt:    opts.Title(),
+		Width:  (img.Width + 1) / 2,
+		Height: (img.Height + 1) / 2,
+		Color:  "#161616",
+		Link:   FormatColor(BleuFrance),
+	}
+	return encode(w, "HTML", func(w io.Writer) error { return signatureHTML.Execute(w, data) })
+}
+
+// safeImageURL indique si l'adresse src d'une image est une URL http(s) ou une image intégrée
+func safeImageURL(src string) bool {
+	s := strings.ToLower(strings.TrimSpace(src))
+	for _, prefix := range []string{"http://", "https://", "data:image/"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeSignatureText écrit la version texte de la signature de l'agent a
+// (pour les courriels sans HTML), avec l'institution et la direction à la place du logo
+func EncodeSignatureText(w io.Writer, a Agent, opts Options) error {
+	opts = opts.normalize()
+	var b strings.Builder
+	for _, l := range []string{a.Name, a.Title} {
+		if l = strings.TrimSpace(l); l != "" {
+			fmt.Fprintln(&b, l)
+		}
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	for _, l := range []string{opts.Institution, opts.Direction} {
+		if l = oneLine(l, opts.EOL); l != "" {
+			fmt.Fprintln(&b, l)
+		}
+	}
+	for _, l := range textLines(a.Address, opts.EOL) {
+		fmt.Fprintln(&b, l)
+	}
+	if a.Phone != "" {
+		fmt.Fprintln(&b, "Tél. : "+strings.TrimSpace(a.Phone))
+	}
+	if a.Email != "" {
+		fmt.Fprintln(&b, strings.TrimSpace(a.Email))
+	}
+	return encode(w, "TXT", func(w io.Writer) error {
+		_, err := io.WriteString(w, b.String())
+		return err
+	})
+}
@@ -0,0 +1,74 @@
+package marianne
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeSignatureHTML(t *testing.T) {
+	a := Agent{Name: "Camille Martin", Title: "Cheffe de projet", Phone: "01 23 45 67 89",
+		Address: "20 avenue de Ségur\\75007 Paris", Email: "camille.martin@exemple.gouv.fr"}
+	img := SignatureImage{Src: "https://exemple.gouv.fr/logos/logo.png", Width: 301, Height: 200}
+	var buf bytes.Buffer
+	if err := EncodeSignatureHTML(&buf, a, img, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{
+		// l'image est affichée à la moitié de sa taille (arrondie au pixel supérieur)
+		`width="151" height="100"`,
+		`width:151px;height:100px;`,
+		`src="https://exemple.gouv.fr/logos/logo.png"`,
+		`href="tel:0123456789"`,
+		"20 avenue de Ségur<br>75007 Paris",
+		`alt="RÉPUBLIQUE FRANÇAISE"`,
+	} {
+		if !strings.Contains(html, s) {
+			t.Errorf("%q manque dans la signature :\n%s", s, html)
+		}
+	}
+}
+
+func TestEncodeSignatureHTMLSource(t *testing.T) {
+	tests := []struct {
+		src string
+		ok  bool
+	}{
+		{"https://exemple.gouv.fr/logo.png", true},
+		{"http://exemple.gouv.fr/logo.png", true},
+		{"data:image/png;base64,iVBORw0KGgo=", true},
+		{"DATA:image/png;base64,iVBORw0KGgo=", true},
+		{"javascript:alert(1)", false},
+		{" JavaScript:alert(1)", false},
+		{"data:text/html;base64,PHNjcmlwdD4=", false},
+		{"logo.png", false},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		err := EncodeSignatureHTML(&buf, Agent{Name: "Camille Martin"}, SignatureImage{Src: tt.src, Width: 100, Height: 50}, Options{})
+		if tt.ok && err != nil {
+			t.Errorf("%q : %v", tt.src, err)
+		}
+		if !tt.ok && KindOf(err) != ParameterError {
+			t.Errorf("%q : erreur %v au lieu d'une erreur de paramètre", tt.src, err)
+		}
+		if !tt.ok && strings.Contains(strings.ToLower(buf.String()), "javascript") {
+			t.Errorf("%q : l'adresse est écrite dans la signature", tt.src)
+		}
+	}
+}
+
+func TestEncodeSignatureText(t *testing.T) {
+	a := Agent{Name: "Camille Martin", Address: "20 avenue de Ségur\\75007 Paris", Phone: "01 23 45 67 89"}
+	opts := DefaultOptions()
+	opts.Direction = "Direction\\du numérique"
+	var buf bytes.Buffer
+	if err := EncodeSignatureText(&buf, a, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "Camille Martin\n\nRÉPUBLIQUE FRANÇAISE\nDirection du numérique\n20 avenue de Ségur\n75007 Paris\nTél. : 01 23 45 67 89\n"
+	if buf.String() != want {
+		t.Errorf("signature texte :\n%s\nau lieu de :\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,701 @@
+package marianne
+
+import (
+	"image"
+	"image/color"
+)
+
+// L'écriture des WebP avec perte (VP8, RFC 6386) : seules les images clés avec des
+// prédictions 16x16 sont utilisées, ce qui suffit pour des logos aux grands aplats.
+
+// les modes de prédiction (16x16 pour la luminance, 8x8 pour la chrominance)
+const (
+	predDC = iota
+	predV
+	predH
+	predTM
+)
+
+// les plans des coefficients (pour les probabilités)
+const (
+	planeYAfterY2 = 0
+	planeY2       = 1
+	planeUV       = 2
+)
+
+var (
+	// l'ordre de parcours des coefficients
+	vp8Zigzag = [16]int{0, 1, 4, 8, 5, 2, 3, 6, 9, 12, 13, 10, 7, 11, 14, 15}
+	// la bande de probabilités de chaque position (dans l'ordre de parcours)
+	vp8Bands = [17]int{0, 1, 2, 3, 6, 4, 5, 6, 6, 6, 6, 6, 6, 6, 6, 7, 0}
+	// les probabilités des bits supplémentaires des catégories 3 à 6
+	vp8Cat3456 = [4][]uint8{
+		{173, 148, 140},
+		{176, 155, 140, 135},
+		{180, 157, 141, 134, 130},
+		{254, 254, 243, 230, 196, 177, 153, 140, 133, 130, 129},
+	}
+)
+
+// boolEncoder est le codeur arithmétique booléen de VP8 (section 7)
+type boolEncoder struct {
+	out      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func newBoolEncoder() *boolEncoder {
+	return &boolEncoder{rng: 255, bitCount: 24}
+}
+
+// écrit le bit b dont la probabilité d'être 0 est prob/256
+func (e *boolEncoder) writeBool(prob uint8, b bool) {
+	split := 1 + ((e.rng-1)*uint32(prob))>>8
+	if b {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			// propagation de la retenue
+			i := len(e.out) - 1
+			for i >= 0 && e.out[i] == 0xff {
+				e.out[i] = 0
+				i--
+			}
+			e.out[i]++
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.out = append(e.out, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// écrit les n bits de v (le bit de poids fort d'abord) avec une probabilité de 1/2
+func (e *boolEncoder) writeLiteral(v uint32, n uint) {
+	for i := n; i > 0; i-- {
+		e.writeBool(128, v>>(i-1)&1 == 1)
+	}
+}
+
+// termine le flux et retourne les octets écrits
+func (e *boolEncoder) bytes() []byte {
+	for i := 0; i < 32; i++ {
+		e.writeBool(128, false)
+	}
+	return e.out
+}
+
+// un plan de l'image (Y, U ou V) avec les dimensions complétées à un multiple des macroblocs
+type vp8Plane struct {
+	pix    []uint8
+	stride int
+}
+
+func (p vp8Plane) at(x, y int) int32 {
+	return int32(p.pix[y*p.stride+x])
+}
+
+// vp8Encoder garde l'état de la compression d'une image
+type vp8Encoder struct {
+	mbw, mbh int
+	// l'image source et l'image reconstruite (celle que verra le décodeur)
+	src, rec [3]vp8Plane
+	// l'indice et les pas de quantification : Y (DC, AC), Y2 (DC, AC), UV (DC, AC)
+	qi          int
+	y1, y2, uvQ [2]int32
+	// la partition des coefficients, les modes et les macroblocs sans coefficient
+	partition *boolEncoder
+	modes     [][2]int
+	skips     []bool
+	// les contextes (blocs non nuls) au-dessus et à gauche : 4 Y, 2 U, 2 V et Y2
+	topNZ  [][9]uint8
+	leftNZ [9]uint8
+}
+
+// encodeVP8 compresse l'image avec perte (le contenu du bloc "VP8 ") ; quality va de 1 à 100
+func encodeVP8(img image.Image, quality int) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	e := &vp8Encoder{mbw: (w + 15) / 16, mbh: (h + 15) / 16}
+	e.qi = (100 - quality) * 127 / 99
+	e.y1 = [2]int32{vp8DCQuant[e.qi], vp8ACQuant[e.qi]}
+	e.y2 = [2]int32{vp8DCQuant[e.qi] * 2, vp8ACQuant[e.qi] * 155 / 100}
+	if e.y2[1] < 8 {
+		e.y2[1] = 8
+	}
+	uvDC := e.qi
+	if uvDC > 117 {
+		uvDC = 117
+	}
+	e.uvQ = [2]int32{vp8DCQuant[uvDC], vp8ACQuant[e.qi]}
+	e.toYUV(img)
+
+	// les macroblocs : les modes et les coefficients vont dans la deuxième partition
+	e.partition = newBoolEncoder()
+	e.topNZ = make([][9]uint8, e.mbw)
+	for mby := 0; mby < e.mbh; mby++ {
+		e.leftNZ = [9]uint8{}
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby)
+		}
+	}
+	second := e.partition.bytes()
+
+	// la première partition : l'en-tête de l'image puis les modes des macroblocs
+	fp := newBoolEncoder()
+	fp.writeLiteral(0, 1) // espace de couleurs
+	fp.writeLiteral(0, 1) // écrêtage
+	fp.writeLiteral(0, 1) // pas de segmentation
+	fp.writeLiteral(0, 1) // filtre normal
+	level := e.qi / 2
+	if level > 63 {
+		level = 63
+	}
+	fp.writeLiteral(uint32(level), 6)
+	fp.writeLiteral(0, 3) // netteté
+	fp.writeLiteral(0, 1) // pas d'ajustement du filtre
+	fp.writeLiteral(0, 2) // une seule partition de coefficients
+	fp.writeLiteral(uint32(e.qi), 7)
+	for i := 0; i < 5; i++ {
+		fp.writeLiteral(0, 1) // pas de décalage des indices de quantification
+	}
+	fp.writeLiteral(0, 1) // les probabilités ne sont pas gardées
+	// les probabilités par défaut des coefficients sont utilisées
+	for i := range vp8CoeffUpdateProbs {
+		for j := range vp8CoeffUpdateProbs[i] {
+			for k := range vp8CoeffUpdateProbs[i][j] {
+				for _, p := range vp8CoeffUpdateProbs[i][j][k] {
+					fp.writeBool(p, false)
+				}
+			}
+		}
+	}
+	// la probabilité des macroblocs sans coefficient
+	nskip := 0
+	for _, s := range e.skips {
+		if s {
+			nskip++
+		}
+	}
+	probSkip := 255 - 255*nskip/len(e.skips)
+	if probSkip < 1 {
+		probSkip = 1
+	}
+	fp.writeLiteral(1, 1)
+	fp.writeLiteral(uint32(probSkip), 8)
+	for i, m := range e.modes {
+		fp.writeBool(uint8(probSkip), e.skips[i])
+		fp.writeBool(145, true) // prédiction 16x16
+		switch m[0] {
+		case predDC:
+			fp.writeBool(156, false)
+			fp.writeBool(163, false)
+		case predV:
+			fp.writeBool(156, false)
+			fp.writeBool(163, true)
+		case predH:
+			fp.writeBool(156, true)
+			fp.writeBool(128, false)
+		case predTM:
+			fp.writeBool(156, true)
+			fp.writeBool(128, true)
+		}
+		fp.writeBool(142, m[1] != predDC)
+		if m[1] != predDC {
+			fp.writeBool(114, m[1] != predV)
+			if m[1] != predV {
+				fp.writeBool(183, m[1] == predTM)
+			}
+		}
+	}
+	first := fp.bytes()
+
+	// l'en-tête de l'image clé
+	out := make([]byte, 10, 10+len(first)+len(second))
+	tag := uint32(1)<<4 | uint32(len(first))<<5 // image clé, version 0, affichée
+	out[0], out[1], out[2] = byte(tag), byte(tag>>8), byte(tag>>16)
+	out[3], out[4], out[5] = 0x9d, 0x01, 0x2a
+	out[6], out[7] = byte(w), byte(w>>8)
+	out[8], out[9] = byte(h), byte(h>>8)
+	out = append(out, first...)
+	return append(out, second...)
+}
+
+// toYUV convertit l'image en Y'CbCr 4:2:0 (BT.601, comme libwebp) en répétant les
+// bords jusqu'aux multiples de 16 ; les pixels transparents deviennent blancs
+func (e *vp8Encoder) toYUV(img image.Image) {
+	b := img.Bounds()
+	w, h := e.mbw*16, e.mbh*16
+	rgb := make([][3]int32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := b.Min.X+x, b.Min.Y+y
+			if sx >= b.Max.X {
+				sx = b.Max.X - 1
+			}
+			if sy >= b.Max.Y {
+				sy = b.Max.Y - 1
+			}
+			c := color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA)
+			if c.A == 0 {
+				c = color.NRGBA{0xff, 0xff, 0xff, 0}
+			}
+			rgb[y*w+x] = [3]int32{int32(c.R), int32(c.G), int32(c.B)}
+		}
+	}
+	for i := range e.src {
+		pw, ph := w, h
+		if i > 0 {
+			pw, ph = w/2, h/2
+		}
+		e.src[i] = vp8Plane{make([]uint8, pw*ph), pw}
+		e.rec[i] = vp8Plane{make([]uint8, pw*ph), pw}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := rgb[y*w+x]
+			e.src[0].pix[y*w+x] = uint8((16839*c[0] + 33059*c[1] + 6420*c[2] + 16<<16 + 1<<15) >> 16)
+		}
+	}
+	for y := 0; y < h/2; y++ {
+		for x := 0; x < w/2; x++ {
+			var r, g, bl int32
+			for _, d := range [4]int{0, 1, w, w + 1} {
+				c := rgb[2*y*w+2*x+d]
+				r, g, bl = r+c[0], g+c[1], bl+c[2]
+			}
+			// la moyenne des 4 pixels est faite avec le décalage (>> 18 au lieu de >> 16)
+			e.src[1].pix[y*w/2+x] = uint8((-9719*r - 19081*g + 28800*bl + 128<<18 + 1<<17) >> 18)
+			e.src[2].pix[y*w/2+x] = uint8((28800*r - 24116*g - 4684*bl + 128<<18 + 1<<17) >> 18)
+		}
+	}
+}
+
+// les bords d'un bloc de taille n : la ligne au-dessus, la colonne à gauche et le coin,
+// avec les valeurs conventionnelles (127 et 129) au bord de l'image
+func (e *vp8Encoder) edges(p vp8Plane, mbx, mby, n int) (top, left []int32, corner int32) {
+	top, left = make([]int32, n), make([]int32, n)
+	x0, y0 := mbx*n, mby*n
+	for i := 0; i < n; i++ {
+		top[i], left[i] = 127, 129
+		if mby > 0 {
+			top[i] = p.at(x0+i, y0-1)
+		}
+		if mbx > 0 {
+			left[i] = p.at(x0-1, y0+i)
+		}
+	}
+	switch {
+	case mby == 0:
+		corner = 127
+	case mbx == 0:
+		corner = 129
+	default:
+		corner = p.at(x0-1, y0-1)
+	}
+	return
+}
+
+// predict calcule la prédiction n x n du mode donné
+func predict(mode, n int, top, left []int32, corner int32, hasTop, hasLeft bool) []int32 {
+	pred := make([]int32, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var v int32
+			switch mode {
+			case predV:
+				v = top[x]
+			case predH:
+				v = left[y]
+			case predTM:
+				v = clamp255(left[y] + top[x] - corner)
+			}
+			pred[y*n+x] = v
+		}
+	}
+	if mode == predDC {
+		// la moyenne des bords disponibles (128 s'il n'y en a aucun)
+		var sum, count int32
+		if hasTop {
+			for _, v := range top {
+				sum += v
+			}
+			count += int32(n)
+		}
+		if hasLeft {
+			for _, v := range left {
+				sum += v
+			}
+			count += int32(n)
+		}
+		dc := int32(128)
+		if count > 0 {
+			dc = (sum + count/2) / count
+		}
+		for i := range pred {
+			pred[i] = dc
+		}
+	}
+	return pred
+}
+
+func clamp255(v int32) int32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+// encodeMacroblock choisit les modes de prédiction du macrobloc, calcule et quantifie
+// ses coefficients, écrit ses coefficients et met à jour l'image reconstruite
+func (e *vp8Encoder) encodeMacroblock(mbx, mby int) {
+	// la luminance : le mode qui laisse le moins d'erreur
+	top, left, corner := e.edges(e.rec[0], mbx, mby, 16)
+	predY := e.bestPrediction([]vp8Plane{e.src[0]}, [][]int32{top}, [][]int32{left}, []int32{corner}, mbx, mby, 16)
+	// la chrominance : le même mode pour U et V
+	var tops, lefts [][]int32
+	var corners []int32
+	for _, p := range e.rec[1:] {
+		t, l, c := e.edges(p, mbx, mby, 8)
+		tops, lefts, corners = append(tops, t), append(lefts, l), append(corners, c)
+	}
+	predC := e.bestPrediction(e.src[1:], tops, lefts, corners, mbx, mby, 8)
+
+	// les coefficients : 16 blocs Y (sans DC), Y2 (les DC des blocs Y), 4 blocs U et 4 blocs V
+	var dc [16]int32
+	var levels [25][16]int32
+	for n := 0; n < 16; n++ {
+		c := e.fdct(e.src[0], predY.pred[0], mbx*16, mby*16, 16, n%4*4, n/4*4)
+		dc[n] = c[0]
+		for i := 1; i < 16; i++ {
+			levels[n][i] = quantize(c[i], e.y1[1], 3)
+		}
+	}
+	y2 := fwht(dc)
+	for i := range y2 {
+		levels[24][i] = quantize(y2[i], e.y2[btoi(i > 0)], 4)
+	}
+	for p := 0; p < 2; p++ {
+		for n := 0; n < 4; n++ {
+			c := e.fdct(e.src[1+p], predC.pred[p], mbx*8, mby*8, 8, n%2*4, n/2*4)
+			for i := range c {
+				levels[16+4*p+n][i] = quantize(c[i], e.uvQ[btoi(i > 0)], 3+btoi(i == 0))
+			}
+		}
+	}
+
+	// la reconstruction (comme le fera le décodeur)
+	var deq [16]int32
+	for i := range deq {
+		deq[i] = levels[24][i] * e.y2[btoi(i > 0)]
+	}
+	dcs := iwht(deq)
+	for n := 0; n < 16; n++ {
+		var c [16]int32
+		c[0] = dcs[n]
+		for i := 1; i < 16; i++ {
+			c[i] = levels[n][i] * e.y1[1]
+		}
+		e.reconstruct(e.rec[0], predY.pred[0], c, mbx*16, mby*16, 16, n%4*4, n/4*4)
+	}
+	for p := 0; p < 2; p++ {
+		for n := 0; n < 4; n++ {
+			var c [16]int32
+			for i := range c {
+				c[i] = levels[16+4*p+n][i] * e.uvQ[btoi(i > 0)]
+			}
+			e.reconstruct(e.rec[1+p], predC.pred[p], c, mbx*8, mby*8, 8, n%2*4, n/2*4)
+		}
+	}
+
+	// les coefficients sont écrits sauf si tous sont nuls
+	skip := true
+	for _, l := range levels {
+		for _, v := range l {
+			if v != 0 {
+				skip = false
+			}
+		}
+	}
+	e.modes = append(e.modes, [2]int{predY.mode, predC.mode})
+	e.skips = append(e.skips, skip)
+	top9, left9 := &e.topNZ[mbx], &e.leftNZ
+	if skip {
+		*top9, *left9 = [9]uint8{}, [9]uint8{}
+		return
+	}
+	nz := e.writeCoeffs(planeY2, top9[8]+left9[8], levels[24], 0)
+	top9[8], left9[8] = nz, nz
+	for n := 0; n < 16; n++ {
+		x, y := n%4, n/4
+		nz := e.writeCoeffs(planeYAfterY2, top9[x]+left9[y], levels[n], 1)
+		top9[x], left9[y] = nz, nz
+	}
+	for p := 0; p < 2; p++ {
+		for n := 0; n < 4; n++ {
+			x, y := 4+2*p+n%2, 4+2*p+n/2
+			nz := e.writeCoeffs(planeUV, top9[x]+left9[y], levels[16+4*p+n], 0)
+			top9[x], left9[y] = nz, nz
+		}
+	}
+}
+
+// une prédiction : le mode et les pixels prédits de chaque plan
+type vp8Prediction struct {
+	mode int
+	pred [][]int32
+}
+
+// bestPrediction retourne le mode de prédiction (commun aux plans) qui laisse le moins d'erreur
+func (e *vp8Encoder) bestPrediction(src []vp8Plane, tops, lefts [][]int32, corners []int32, mbx, mby, n int) vp8Prediction {
+	best, bestErr := vp8Prediction{}, int64(-1)
+	for mode := predDC; mode <= predTM; mode++ {
+		p := vp8Prediction{mode: mode}
+		var sse int64
+		for i, s := range src {
+			pred := predict(mode, n, tops[i], lefts[i], corners[i], mby > 0, mbx > 0)
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					d := int64(s.at(mbx*n+x, mby*n+y) - pred[y*n+x])
+					sse += d * d
+				}
+			}
+			p.pred = append(p.pred, pred)
+		}
+		if bestErr < 0 || sse < bestErr {
+			best, bestErr = p, sse
+		}
+	}
+	return best
+}
+
+// fdct calcule la transformée en cosinus du bloc 4x4 (bx, by) de la différence entre le
+// plan p et la prédiction pred du macrobloc (x0, y0) de taille n
+func (e *vp8Encoder) fdct(p vp8Plane, pred []int32, x0, y0, n, bx, by int) [16]int32 {
+	var tmp, out [16]int32
+	for i := 0; i < 4; i++ {
+		var d [4]int32
+		for j := range d {
+			d[j] = p.at(x0+bx+j, y0+by+i) - pred[(by+i)*n+bx+j]
+		}
+		a1 := (d[0] + d[3]) * 8
+		b1 := (d[1] + d[2]) * 8
+		c1 := (d[1] - d[2]) * 8
+		d1 := (d[0] - d[3]) * 8
+		tmp[4*i+0] = a1 + b1
+		tmp[4*i+2] = a1 - b1
+		tmp[4*i+1] = (c1*2217 + d1*5352 + 14500) >> 12
+		tmp[4*i+3] = (d1*2217 - c1*5352 + 7500) >> 12
+	}
+	for i := 0; i < 4; i++ {
+		a1 := tmp[i] + tmp[12+i]
+		b1 := tmp[4+i] + tmp[8+i]
+		c1 := tmp[4+i] - tmp[8+i]
+		d1 := tmp[i] - tmp[12+i]
+		out[i] = (a1 + b1 + 7) >> 4
+		out[8+i] = (a1 - b1 + 7) >> 4
+		out[4+i] = (c1*2217+d1*5352+12000)>>16 + btoi(d1 != 0)
+		out[12+i] = (d1*2217 - c1*5352 + 51000) >> 16
+	}
+	return out
+}
+
+// reconstruct ajoute à la prédiction la transformée inverse des coefficients c
+// (comme le décodeur) et écrit le résultat dans le plan reconstruit p
+func (e *vp8Encoder) reconstruct(p vp8Plane, pred []int32, c [16]int32, x0, y0, n, bx, by int) {
+	const (
+		c1 = 85627 // 65536 * cos(pi/8) * sqrt(2)
+		c2 = 35468 // 65536 * sin(pi/8) * sqrt(2)
+	)
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := c[i] + c[8+i]
+		b := c[i] - c[8+i]
+		cc := (c[4+i]*c2)>>16 - (c[12+i]*c1)>>16
+		d := (c[4+i]*c1)>>16 + (c[12+i]*c2)>>16
+		m[i] = [4]int32{a + d, b + cc, b - cc, a - d}
+	}
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		cc := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		for i, v := range [4]int32{a + d, b + cc, b - cc, a - d} {
+			p.pix[(y0+by+j)*p.stride+x0+bx+i] = uint8(clamp255(pred[(by+j)*n+bx+i] + v>>3))
+		}
+	}
+}
+
+// la transformée de Walsh-Hadamard des 16 DC (l'inverse de iwht)
+func fwht(dc [16]int32) [16]int32 {
+	// avec M la matrice de iwht (M·Mᵀ = 4·I) : C = Mᵀ·S·M / 2
+	m := [4][4]int32{{1, 1, 1, 1}, {1, 1, -1, -1}, {1, -1, -1, 1}, {1, -1, 1, -1}}
+	var t, out [16]int32
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				t[i*4+j] += m[k][i] * dc[k*4+j]
+			}
+		}
+	}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			var s int32
+			for k := 0; k < 4; k++ {
+				s += t[i*4+k] * m[k][j]
+			}
+			if s < 0 {
+				out[i*4+j] = -((-s + 1) / 2)
+			} else {
+				out[i*4+j] = (s + 1) / 2
+			}
+		}
+	}
+	return out
+}
+
+// la transformée de Walsh-Hadamard inverse (comme le décodeur)
+func iwht(c [16]int32) [16]int32 {
+	var m, out [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := c[i] + c[12+i]
+		a1 := c[4+i] + c[8+i]
+		a2 := c[4+i] - c[8+i]
+		a3 := c[i] - c[12+i]
+		m[i], m[8+i], m[4+i], m[12+i] = a0+a1, a0-a1, a3+a2, a3-a2
+	}
+	for i := 0; i < 4; i++ {
+		dc := m[i*4] + 3
+		a0 := dc + m[3+i*4]
+		a1 := m[1+i*4] + m[2+i*4]
+		a2 := m[1+i*4] - m[2+i*4]
+		a3 := dc - m[3+i*4]
+		out[i*4], out[i*4+1], out[i*4+2], out[i*4+3] = (a0+a1)>>3, (a3+a2)>>3, (a0-a1)>>3, (a3-a2)>>3
+	}
+	return out
+}
+
+// quantize divise le coefficient c par le pas q en arrondissant (un arrondi vers zéro
+// plus marqué quand bias est petit : bias/8 du pas est ajouté avant la division)
+func quantize(c, q int32, bias int32) int32 {
+	a := c
+	if a < 0 {
+		a = -a
+	}
+	l := (a + q*bias/8) / q
+	if l > 2048 {
+		l = 2048
+	}
+	if c < 0 {
+		return -l
+	}
+	return l
+}
+
+func btoi(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// writeCoeffs écrit les coefficients quantifiés d'un bloc (à partir de la position first)
+// avec le contexte ctx, et retourne 1 si le bloc a des coefficients non nuls
+func (e *vp8Encoder) writeCoeffs(plane int, ctx uint8, levels [16]int32, first int) uint8 {
+	probs := &vp8DefaultCoeffProbs[plane]
+	last := -1
+	for i := first; i < 16; i++ {
+		if levels[vp8Zigzag[i]] != 0 {
+			last = i
+		}
+	}
+	n := first
+	p := probs[vp8Bands[n]][ctx]
+	if last < 0 {
+		e.partition.writeBool(p[0], false) // fin du bloc
+		return 0
+	}
+	e.partition.writeBool(p[0], true)
+	for n < 16 {
+		v := levels[vp8Zigzag[n]]
+		n++
+		if v == 0 {
+			e.partition.writeBool(p[1], false)
+			p = probs[vp8Bands[n]][0]
+			continue
+		}
+		e.partition.writeBool(p[1], true)
+		a := v
+		if a < 0 {
+			a = -a
+		}
+		if a == 1 {
+			e.partition.writeBool(p[2], false)
+			p = probs[vp8Bands[n]][1]
+		} else {
+			e.partition.writeBool(p[2], true)
+			e.writeLarge(p, a)
+			p = probs[vp8Bands[n]][2]
+		}
+		e.partition.writeBool(128, v < 0)
+		if n == 16 {
+			break
+		}
+		e.partition.writeBool(p[0], n-1 != last)
+		if n-1 == last {
+			break
+		}
+	}
+	return 1
+}
+
+// écrit la valeur absolue a (a >= 2) d'un coefficient
+func (e *vp8Encoder) writeLarge(p [11]uint8, a int32) {
+	pe := e.partition
+	switch {
+	case a <= 4:
+		pe.writeBool(p[3], false)
+		pe.writeBool(p[4], a != 2)
+		if a != 2 {
+			pe.writeBool(p[5], a == 4)
+		}
+	case a <= 10:
+		pe.writeBool(p[3], true)
+		pe.writeBool(p[6], false)
+		if a <= 6 {
+			pe.writeBool(p[7], false)
+			pe.writeBool(159, a == 6)
+		} else {
+			pe.writeBool(p[7], true)
+			pe.writeBool(165, (a-7)>>1 == 1)
+			pe.writeBool(145, (a-7)&1 == 1)
+		}
+	default:
+		pe.writeBool(p[3], true)
+		pe.writeBool(p[6], true)
+		cat := 0
+		for cat < 3 && a >= 3+(8<<uint(cat+1)) {
+			cat++
+		}
+		pe.writeBool(p[8], cat >= 2)
+		pe.writeBool(p[9+cat/2], cat&1 == 1)
+		extra := a - 3 - (8 << uint(cat))
+		tab := vp8Cat3456[cat]
+		for i, prob := range tab {
+			pe.writeBool(prob, extra>>uint(len(tab)-1-i)&1 == 1)
+		}
+	}
+}
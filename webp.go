@@ -0,0 +1,594 @@
+package marianne
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// L'écriture des images WebP en Go pur (pour garder des binaires sans cgo) :
+// sans perte avec VP8L, ou avec perte avec VP8 (voir vp8.go).
+
+// writeWebP écrit l'image img en WebP : sans perte si quality vaut 0, sinon avec perte
+// (la couche alpha éventuelle est alors enregistrée sans perte à côté de l'image)
+func writeWebP(w io.Writer, img image.Image, quality int) error {
+	b := img.Bounds()
+	if b.Dx() > 1<<14 || b.Dy() > 1<<14 {
+		return parameterError("image trop grande pour le WebP : %dx%d (au plus 16384 pixels de côté)", b.Dx(), b.Dy())
+	}
+	var chunks bytes.Buffer
+	if quality == 0 {
+		riffChunk(&chunks, "VP8L", encodeVP8L(img))
+	} else {
+		if alpha := alphaImage(img); alpha != nil {
+			// VP8X annonce la couche alpha, puis ALPH contient l'alpha compressé en VP8L
+			var x [10]byte
+			x[0] = 0x10
+			putUint24(x[4:], b.Dx()-1)
+			putUint24(x[7:], b.Dy()-1)
+			riffChunk(&chunks, "VP8X", x[:])
+			riffChunk(&chunks, "ALPH", append([]byte{1}, encodeVP8L(alpha)[5:]...))
+		}
+		riffChunk(&chunks, "VP8 ", encodeVP8(img, quality))
+	}
+	var h [12]byte
+	copy(h[:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], uint32(4+chunks.Len()))
+	copy(h[8:], "WEBP")
+	if _, err := w.Write(h[:]); err != nil {
+		return err
+	}
+	_, err := chunks.WriteTo(w)
+	return err
+}
+
+// ajoute un bloc RIFF (avec un octet de remplissage si sa taille est impaire)
+func riffChunk(b *bytes.Buffer, fourcc string, data []byte) {
+	b.WriteString(fourcc)
+	binary.Write(b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	if len(data)%2 == 1 {
+		b.WriteByte(0)
+	}
+}
+
+// écrit un entier sur 3 octets (petit-boutiste)
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// alphaImage retourne la couche alpha de img dans le canal vert d'une image
+// (comme l'attend le bloc ALPH), ou nil si l'image est opaque
+func alphaImage(img image.Image) image.Image {
+	b := img.Bounds()
+	alpha := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	opaque := true
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if a != 0xffff {
+				opaque = false
+			}
+			alpha.SetNRGBA(x-b.Min.X, y-b.Min.Y, color.NRGBA{0, uint8(a >> 8), 0, 0xff})
+		}
+	}
+	if opaque {
+		return nil
+	}
+	return alpha
+}
+
+// bitWriter écrit les bits du flux VP8L (en commençant par les bits de poids faible)
+type bitWriter struct {
+	buf []byte
+	acc uint64
+	n   uint
+}
+
+// écrit les n bits de poids faible de v
+func (b *bitWriter) write(v uint32, n uint) {
+	b.acc |= uint64(v) << b.n
+	b.n += n
+	for b.n >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.n -= 8
+	}
+}
+
+// retourne les octets écrits (en complétant le dernier octet)
+func (b *bitWriter) bytes() []byte {
+	if b.n > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.n = 0, 0
+	}
+	return b.buf
+}
+
+// encodeVP8L compresse l'image sans perte (le contenu du bloc "VP8L")
+func encodeVP8L(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// les pixels en ARGB (non prémultipliés)
+	argb := make([]uint32, 0, w*h)
+	alphaUsed := uint32(0)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A != 0xff {
+				alphaUsed = 1
+			}
+			argb = append(argb, uint32(c.A)<<24|uint32(c.R)<<16|uint32(c.G)<<8|uint32(c.B))
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	bw.write(alphaUsed, 1)
+	bw.write(0, 3)
+
+	if palette := vp8lPalette(argb); palette != nil {
+		// transformation par indexation des couleurs (au plus 256 couleurs)
+		bw.write(1, 1)
+		bw.write(3, 2)
+		bw.write(uint32(len(palette)-1), 8)
+		// la palette est codée par différences avec la couleur précédente
+		table := make([]uint32, len(palette))
+		for i, c := range palette {
+			if i == 0 {
+				table[i] = c
+			} else {
+				table[i] = subPixels(c, palette[i-1])
+			}
+		}
+		writeVP8LImage(bw, table, len(table), false)
+		argb, w = bundlePixels(argb, w, h, palette)
+	} else {
+		// transformation par soustraction du vert
+		bw.write(1, 1)
+		bw.write(2, 2)
+		for i, c := range argb {
+			g := (c >> 8) & 0xff
+			argb[i] = c&0xff00ff00 | ((c>>16-g)&0xff)<<16 | (c-g)&0xff
+		}
+	}
+	bw.write(0, 1)
+	writeVP8LImage(bw, argb, w, true)
+	return bw.bytes()
+}
+
+// la liste triée des couleurs de l'image, ou nil s'il y en a plus de 256
+func vp8lPalette(argb []uint32) []uint32 {
+	seen := map[uint32]bool{}
+	for _, c := range argb {
+		if !seen[c] {
+			if len(seen) == 256 {
+				return nil
+			}
+			seen[c] = true
+		}
+	}
+	palette := make([]uint32, 0, len(seen))
+	for c := range seen {
+		palette = append(palette, c)
+	}
+	sort.Slice(palette, func(i, j int) bool { return palette[i] < palette[j] })
+	return palette
+}
+
+// la différence, composante par composante, de deux pixels ARGB
+func subPixels(a, b uint32) uint32 {
+	var r uint32
+	for s := uint(0); s < 32; s += 8 {
+		r |= ((a>>s - b>>s) & 0xff) << s
+	}
+	return r
+}
+
+// remplace les pixels par leurs indices dans la palette en regroupant plusieurs
+// pixels par octet pour les petites palettes ; retourne les pixels et la nouvelle largeur
+func bundlePixels(argb []uint32, w, h int, palette []uint32) ([]uint32, int) {
+	index := map[uint32]uint32{}
+	for i, c := range palette {
+		index[c] = uint32(i)
+	}
+	var xbits uint
+	switch n := len(palette); {
+	case n <= 2:
+		xbits = 3
+	case n <= 4:
+		xbits = 2
+	case n <= 16:
+		xbits = 1
+	}
+	bw := (w + 1<<xbits - 1) >> xbits
+	bits := 8 >> xbits
+	packed := make([]uint32, bw*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := &packed[y*bw+x>>xbits]
+			*p |= 0xff000000 | index[argb[y*w+x]]<<(8+uint(x&(1<<xbits-1)*bits))
+		}
+	}
+	return packed, bw
+}
+
+// un élément du flux VP8L : un pixel, ou une copie de length pixels à la distance dist
+type vp8lToken struct {
+	argb         uint32
+	length, dist int
+}
+
+// lz77 découpe les pixels en pixels littéraux et en copies de pixels précédents
+func lz77(pix []uint32, w int) []vp8lToken {
+	const (
+		minLength = 3
+		maxLength = 4096
+		maxChain  = 32
+		hashBits  = 16
+	)
+	n := len(pix)
+	head := make([]int, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int, n)
+	hash := func(i int) uint32 {
+		return (pix[i]*0x9e3779b1 ^ pix[i+1]*0x85ebca6b) >> (32 - hashBits)
+	}
+	insert := func(i int) {
+		if i+1 < n {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = i
+		}
+	}
+	matchLength := func(i, j int) int {
+		l := 0
+		for i+l < n && l < maxLength && pix[j+l] == pix[i+l] {
+			l++
+		}
+		return l
+	}
+
+	var tokens []vp8lToken
+	for i := 0; i < n; {
+		bestLength, bestDist := 0, 0
+		// les pixels à gauche et au-dessus, puis ceux qui ont le même début
+		try := func(j int) {
+			if j >= 0 && j < i {
+				if l := matchLength(i, j); l > bestLength {
+					bestLength, bestDist = l, i-j
+				}
+			}
+		}
+		try(i - 1)
+		try(i - w)
+		if i+1 < n {
+			for j, c := head[hash(i)], 0; j >= 0 && c < maxChain && bestLength < maxLength; j, c = prev[j], c+1 {
+				try(j)
+			}
+		}
+		if bestLength >= minLength {
+			tokens = append(tokens, vp8lToken{length: bestLength, dist: bestDist})
+			for k := 0; k < bestLength; k++ {
+				insert(i + k)
+			}
+			i += bestLength
+		} else {
+			tokens = append(tokens, vp8lToken{argb: pix[i]})
+			insert(i)
+			i++
+		}
+	}
+	return tokens
+}
+
+// le code préfixe d'une longueur ou d'une distance v (v >= 1) et ses bits supplémentaires
+func prefixEncode(v int) (code int, nExtra uint, extra uint32) {
+	d := v - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	hb := uint(0)
+	for d>>(hb+1) != 0 {
+		hb++
+	}
+	second := (d >> (hb - 1)) & 1
+	nExtra = hb - 1
+	return int(2*hb) + second, nExtra, uint32(d) & (1<<nExtra - 1)
+}
+
+// le code de distance VP8L : les distances vers le pixel au-dessus et vers le pixel à
+// gauche ont des codes courts, les autres sont décalées de 120
+func distanceCode(dist, w int) int {
+	switch dist {
+	case w:
+		return 1
+	case 1:
+		return 2
+	}
+	return dist + 120
+}
+
+// writeVP8LImage écrit une image (sans cache de couleurs) avec ses codes de Huffman ;
+// top indique l'image principale (qui peut avoir plusieurs groupes de codes)
+func writeVP8LImage(bw *bitWriter, pix []uint32, w int, top bool) {
+	bw.write(0, 1) // pas de cache de couleurs
+	if top {
+		bw.write(0, 1) // un seul groupe de codes
+	}
+	tokens := lz77(pix, w)
+
+	// les histogrammes : vert (et longueurs), rouge, bleu, alpha, distances
+	counts := [5][]int{make([]int, 256+24), make([]int, 256), make([]int, 256), make([]int, 256), make([]int, 40)}
+	for _, t := range tokens {
+		if t.length == 0 {
+			counts[0][t.argb>>8&0xff]++
+			counts[1][t.argb>>16&0xff]++
+			counts[2][t.argb&0xff]++
+			counts[3][t.argb>>24]++
+		} else {
+			c, _, _ := prefixEncode(t.length)
+			counts[0][256+c]++
+			c, _, _ = prefixEncode(distanceCode(t.dist, w))
+			counts[4][c]++
+		}
+	}
+	var codes [5]huffmanCode
+	for i := range codes {
+		codes[i] = writeHuffmanCode(bw, counts[i])
+	}
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			codes[0].write(bw, int(t.argb>>8&0xff))
+			codes[1].write(bw, int(t.argb>>16&0xff))
+			codes[2].write(bw, int(t.argb&0xff))
+			codes[3].write(bw, int(t.argb>>24))
+			continue
+		}
+		c, n, e := prefixEncode(t.length)
+		codes[0].write(bw, 256+c)
+		bw.write(e, n)
+		c, n, e = prefixEncode(distanceCode(t.dist, w))
+		codes[4].write(bw, c)
+		bw.write(e, n)
+	}
+}
+
+// huffmanCode est un code de Huffman canonique (les codes sont inversés pour être écrits
+// bit par bit) ; un code avec un seul symbole ne prend aucun bit
+type huffmanCode struct {
+	lengths []uint8
+	codes   []uint32
+	single  bool
+}
+
+// écrit le code du symbole s
+func (h huffmanCode) write(bw *bitWriter, s int) {
+	if !h.single {
+		bw.write(h.codes[s], uint(h.lengths[s]))
+	}
+}
+
+// newHuffmanCode construit le code canonique correspondant aux longueurs
+func newHuffmanCode(lengths []uint8) huffmanCode {
+	h := huffmanCode{lengths: lengths, codes: make([]uint32, len(lengths))}
+	var count [16]uint32
+	used := 0
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+			used++
+		}
+	}
+	h.single = used == 1
+	var next [16]uint32
+	code := uint32(0)
+	for l := 1; l < 16; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+		// inversion des bits
+		var r uint32
+		for i := uint8(0); i < l; i++ {
+			r = r<<1 | (c>>i)&1
+		}
+		h.codes[s] = r
+	}
+	return h
+}
+
+// un nœud pour la construction de l'arbre de Huffman
+type huffmanNode struct {
+	count       int
+	symbol      int
+	left, right *huffmanNode
+}
+
+// une file de priorité de nœuds (le plus petit effectif d'abord)
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].count == h[j].count {
+		return h[i].symbol < h[j].symbol
+	}
+	return h[i].count < h[j].count
+}
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// huffmanLengths calcule les longueurs des codes de Huffman (au plus maxLength bits)
+// à partir des effectifs des symboles
+func huffmanLengths(counts []int, maxLength uint8) []uint8 {
+	lengths := make([]uint8, len(counts))
+	c := append([]int(nil), counts...)
+	for {
+		h := &huffmanHeap{}
+		for s, n := range c {
+			if n > 0 {
+				*h = append(*h, &huffmanNode{count: n, symbol: s})
+			}
+		}
+		if h.Len() == 0 {
+			return lengths
+		}
+		if h.Len() == 1 {
+			lengths[(*h)[0].symbol] = 1
+			return lengths
+		}
+		heap.Init(h)
+		for h.Len() > 1 {
+			a := heap.Pop(h).(*huffmanNode)
+			b := heap.Pop(h).(*huffmanNode)
+			heap.Push(h, &huffmanNode{count: a.count + b.count, symbol: -1, left: a, right: b})
+		}
+		ok := true
+		var walk func(n *huffmanNode, depth uint8)
+		walk = func(n *huffmanNode, depth uint8) {
+			if n.left == nil {
+				lengths[n.symbol] = depth
+				if depth > maxLength {
+					ok = false
+				}
+				return
+			}
+			walk(n.left, depth+1)
+			walk(n.right, depth+1)
+		}
+		walk((*h)[0], 0)
+		if ok {
+			return lengths
+		}
+		// les codes sont trop longs : on aplatit les effectifs et on recommence
+		for s, n := range c {
+			if n > 0 {
+				c[s] = n/2 + 1
+			}
+		}
+	}
+}
+
+// l'ordre dans lequel sont écrites les longueurs du code des longueurs
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writeHuffmanCode écrit le code de Huffman des symboles d'effectifs counts et le retourne
+func writeHuffmanCode(bw *bitWriter, counts []int) huffmanCode {
+	var symbols []int
+	for s, n := range counts {
+		if n > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) == 0 {
+		symbols = []int{0}
+	}
+
+	// code simple : un ou deux symboles inférieurs à 256
+	if len(symbols) <= 2 && symbols[len(symbols)-1] < 256 {
+		bw.write(1, 1)
+		bw.write(uint32(len(symbols)-1), 1)
+		if symbols[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(symbols[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(symbols[0]), 8)
+		}
+		lengths := make([]uint8, len(counts))
+		if len(symbols) == 2 {
+			bw.write(uint32(symbols[1]), 8)
+			lengths[symbols[0]], lengths[symbols[1]] = 1, 1
+		} else {
+			lengths[symbols[0]] = 1
+		}
+		return newHuffmanCode(lengths)
+	}
+
+	// code normal : les longueurs sont compressées avec les codes de répétition 16, 17 et 18
+	lengths := huffmanLengths(counts, 15)
+	type rle struct {
+		code  int
+		extra uint32
+		n     uint
+	}
+	var tokens []rle
+	for i := 0; i < len(lengths); {
+		l := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == l {
+			run++
+		}
+		i += run
+		if l == 0 {
+			for run >= 11 {
+				r := min(run, 138)
+				tokens = append(tokens, rle{18, uint32(r - 11), 7})
+				run -= r
+			}
+			if run >= 3 {
+				tokens = append(tokens, rle{17, uint32(run - 3), 3})
+				run = 0
+			}
+		} else {
+			tokens = append(tokens, rle{int(l), 0, 0})
+			run--
+			for run >= 3 {
+				r := min(run, 6)
+				tokens = append(tokens, rle{16, uint32(r - 3), 2})
+				run -= r
+			}
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, rle{int(l), 0, 0})
+		}
+	}
+	clCounts := make([]int, 19)
+	for _, t := range tokens {
+		clCounts[t.code]++
+	}
+	clCode := newHuffmanCode(huffmanLengths(clCounts, 7))
+	n := 19
+	for n > 4 && clCode.lengths[codeLengthCodeOrder[n-1]] == 0 {
+		n--
+	}
+	bw.write(0, 1)
+	bw.write(uint32(n-4), 4)
+	for _, s := range codeLengthCodeOrder[:n] {
+		bw.write(uint32(clCode.lengths[s]), 3)
+	}
+	bw.write(0, 1) // toutes les longueurs sont écrites
+	for _, t := range tokens {
+		clCode.write(bw, t.code)
+		bw.write(t.extra, t.n)
+	}
+	return newHuffmanCode(lengths)
+}
+
+// le plus petit de deux entiers
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
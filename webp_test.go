@@ -0,0 +1,146 @@
+package marianne
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp" // le décodeur de référence
+)
+
+// sameNRGBA vérifie que les images a et b ont les mêmes pixels (sur 8 bits par composante),
+// la couleur des pixels entièrement transparents mise à part
+func sameNRGBA(t *testing.T, name string, a, b image.Image) {
+	t.Helper()
+	if a.Bounds().Size() != b.Bounds().Size() {
+		t.Fatalf("%s : taille %v au lieu de %v", name, b.Bounds().Size(), a.Bounds().Size())
+	}
+	ra, rb := a.Bounds(), b.Bounds()
+	for y := 0; y < ra.Dy(); y++ {
+		for x := 0; x < ra.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ra.Min.X+x, ra.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(rb.Min.X+x, rb.Min.Y+y)).(color.NRGBA)
+			if ca.A == 0 && cb.A == 0 {
+				continue
+			}
+			if ca != cb {
+				t.Fatalf("%s : le pixel (%d, %d) est %v au lieu de %v", name, x, y, cb, ca)
+			}
+		}
+	}
+}
+
+func TestWebPLossless(t *testing.T) {
+	logo := func(opts Options) image.Image {
+		c, err := Render(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return CanvasToImage(c, 0, 120, opts)
+	}
+	r := rand.New(rand.NewSource(1))
+	random := image.NewNRGBA(image.Rect(0, 0, 37, 23))
+	r.Read(random.Pix)
+	images := map[string]image.Image{
+		"logo":        logo(Options{}),
+		"transparent": logo(Options{Transparent: true}),
+		"aléatoire":   random,
+		"un pixel":    image.NewNRGBA(image.Rect(0, 0, 1, 1)),
+	}
+	for name, img := range images {
+		var buf bytes.Buffer
+		if err := writeWebP(&buf, img, 0); err != nil {
+			t.Fatalf("%s : %v", name, err)
+		}
+		decoded, err := webp.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s : %v", name, err)
+		}
+		sameNRGBA(t, name, img, decoded)
+	}
+}
+
+// webpRGB retourne la couleur du pixel (x, y) de l'image WebP décodée img : le décodeur
+// donne le Y'CbCr de VP8, converti ici comme libwebp (BT.601, Y' de 16 à 235) et non
+// comme color.YCbCr (JFIF, Y' de 0 à 255)
+func webpRGB(img image.Image, x, y int) (r, g, b, a float64) {
+	var yc *image.YCbCr
+	a = 255
+	switch img := img.(type) {
+	case *image.YCbCr:
+		yc = img
+	case *image.NYCbCrA:
+		yc = &img.YCbCr
+		a = float64(img.A[img.AOffset(x, y)])
+	default:
+		panic("image WebP inattendue")
+	}
+	l := 1.164 * (float64(yc.Y[yc.YOffset(x, y)]) - 16)
+	cb, cr := float64(yc.Cb[yc.COffset(x, y)])-128, float64(yc.Cr[yc.COffset(x, y)])-128
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(255, math.Round(v))) }
+	return clamp(l + 1.596*cr), clamp(l - 0.391*cb - 0.813*cr), clamp(l + 2.018*cb), a
+}
+
+func TestWebPLossy(t *testing.T) {
+	// le PSNR minimal (en dB) en fonction de la qualité
+	tests := []struct {
+		quality int
+		psnr    float64
+	}{{10, 25}, {50, 32}, {80, 38}, {95, 42}}
+	for _, transparent := range []bool{false, true} {
+		opts := DefaultOptions()
+		opts.Direction = "Direction générale"
+		opts.Transparent = transparent
+		c, err := Render(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := CanvasToImage(c, 0, 200, opts)
+		b := img.Bounds()
+		previous := 0
+		for _, tt := range tests {
+			var buf bytes.Buffer
+			if err := writeWebP(&buf, img, tt.quality); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("transparent %v qualité %d : %v", transparent, tt.quality, err)
+			}
+			if decoded.Bounds().Size() != b.Size() {
+				t.Fatalf("transparent %v qualité %d : taille %v au lieu de %v", transparent, tt.quality, decoded.Bounds().Size(), b.Size())
+			}
+			// l'erreur quadratique moyenne des couleurs prémultipliées par l'alpha (la couleur des
+			// pixels presque transparents compte peu) ; l'alpha est sans perte
+			var sum float64
+			var n int
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					s := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+					r, g, bl, a := webpRGB(decoded, x, y)
+					if a != float64(s.A) {
+						t.Fatalf("transparent %v qualité %d : l'alpha du pixel (%d, %d) est %g au lieu de %d", transparent, tt.quality, x, y, a, s.A)
+					}
+					k := a / 255
+					for _, d := range []float64{r - float64(s.R), g - float64(s.G), bl - float64(s.B)} {
+						sum += d * d * k * k
+					}
+					n += 3
+				}
+			}
+			psnr := 10 * math.Log10(255*255/(sum/float64(n)))
+			if psnr < tt.psnr {
+				t.Errorf("transparent %v qualité %d : PSNR %.1f dB (au moins %g attendus)", transparent, tt.quality, psnr, tt.psnr)
+			}
+			// une meilleure qualité donne un fichier plus gros
+			if buf.Len() <= previous {
+				t.Errorf("transparent %v qualité %d : %d octets (%d pour la qualité précédente)", transparent, tt.quality, buf.Len(), previous)
+			}
+			previous = buf.Len()
+			t.Logf("transparent %v qualité %d : %d octets, PSNR %.1f dB", transparent, tt.quality, buf.Len(), psnr)
+		}
+	}
+}